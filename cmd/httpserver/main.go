@@ -1,16 +1,22 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"httpfromtcp/internal/request"
 	"httpfromtcp/internal/response"
 	"httpfromtcp/internal/server"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
-	"strings"
+	"strconv"
 	"syscall"
+	"time"
 )
 
 const PORT = 42069
@@ -22,102 +28,145 @@ var client = &http.Client{
 }
 
 func main() {
-	server, err := server.Serve(PORT, func(w *response.Writer, req *request.Request) {
-		w.Headers.Set("content-type", "text/html")
+	router := server.NewRouter()
+	router.Handle("/yourproblem", server.HandleErrors(handleYourProblem))
+	router.Handle("/myproblem", server.HandleErrors(handleMyProblem))
+	router.Handle("GET /httpbin/{path...}", server.HandleErrors(handleHTTPBin))
+	router.Handle("GET /video", server.HandleErrors(handleVideo))
+	router.Handle("/", handleRoot)
+
+	config := server.Config{
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       60 * time.Second,
+		RouteLimits:       router.Limits,
+	}
 
-		if req.RequestLine.RequestTarget == "/yourproblem" {
-			w.Status = response.BAD_REQUEST
+	handler := server.Chain(router.ServeRequest, server.AccessLog)
 
-			body := `<html>
-  <head>
-    <title>400 Bad Request</title>
-  </head>
-  <body>
-    <h1>Bad Request</h1>
-    <p>Your request honestly kinda sucked.</p>
-  </body>
-</html>`
+	server, err := server.ServeConfig(PORT, handler, config)
+	if err != nil {
+		log.Fatalf("Error starting server: %v", err)
+	}
 
-			w.SetBody([]byte(body))
-			return
-		}
+	log.Println("Server started on port:", PORT)
 
-		if req.RequestLine.RequestTarget == "/myproblem" {
-			w.Status = response.INTERNAL_SERVER_ERROR
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	<-sigChan
 
-			body := `<html>
-  <head>
-    <title>500 Internal Server Error</title>
-  </head>
-  <body>
-    <h1>Internal Server Error</h1>
-    <p>Okay, you know what? This one is on me.</p>
-  </body>
-</html>`
+	// Let in-flight requests finish before exiting.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Server shutdown cut short: %v", err)
+		return
+	}
+
+	log.Println("Server gracefully stopped")
+}
+
+func handleYourProblem(w *response.Writer, req *request.Request) error {
+	return &server.HandlerError{
+		StatusCode: response.BAD_REQUEST,
+		Message:    "Your request honestly kinda sucked.",
+	}
+}
+
+func handleMyProblem(w *response.Writer, req *request.Request) error {
+	return &server.HandlerError{
+		StatusCode: response.INTERNAL_SERVER_ERROR,
+		Message:    "Okay, you know what? This one is on me.",
+	}
+}
+
+func handleHTTPBin(w *response.Writer, req *request.Request) error {
+	// Forward the decoded path and the query string as sent.
+	u := url.URL{
+		Scheme:   "https",
+		Host:     "httpbin.org",
+		Path:     "/" + req.Param("path"),
+		RawQuery: req.RequestLine.Target.RawQuery,
+	}
+	upstream, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	// Just to be explicit:
+	upstream.Proto = "HTTP/1.1"
+	upstream.ProtoMajor = 1
+	upstream.ProtoMinor = 1
+
+	resp, err := client.Do(upstream)
+	if err != nil {
+		return fmt.Errorf("httpbin: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Relay upstream bytes as they arrive, one chunk per upstream read,
+	// flushed right away so streamed endpoints are not held back.
+	// The hash and length of what was relayed follow as trailers.
+	w.ChunkPerWrite = true
+	w.Status = response.OK
+	w.Headers.Set("content-type", "text/plain")
+	if err := w.DeclareTrailer("X-Content-SHA256", "X-Content-Length"); err != nil {
+		return err
+	}
+
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(flushWriter{w}, hash), resp.Body)
+	if err != nil {
+		return fmt.Errorf("httpbin: %w", err)
+	}
+
+	w.Trailers.Set("X-Content-SHA256", hex.EncodeToString(hash.Sum(nil)))
+	w.Trailers.Set("X-Content-Length", strconv.FormatInt(n, 10))
+	return nil
+}
+
+// flushWriter sends every Write to the client as soon as it is made.
+type flushWriter struct {
+	w *response.Writer
+}
+
+func (f flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	return n, f.w.Flush()
+}
+
+func handleVideo(w *response.Writer, req *request.Request) error {
+	f, err := os.Open("/assets/vim.mp4")
+	if err != nil {
+		return &server.HandlerError{StatusCode: response.NOT_FOUND, Message: "No video here."}
+	}
+	defer f.Close()
+
+	// Stream the file instead of holding it all in memory.
+	w.Status = response.OK
+	w.Headers.Set("content-type", "video/mp4")
+	if err := w.DeclareTrailer("X-Content-SHA256"); err != nil {
+		return err
+	}
 
-			w.SetBody([]byte(body))
-			return
-		}
-
-		if strings.HasPrefix(req.RequestLine.RequestTarget, "/httpbin/stream") {
-			w.Status = response.OK
-			w.Headers.Set("Transfer-Encoding", "chunked")
-			w.Headers.Override("content-type", "text/plain")
-
-			target := req.RequestLine.RequestTarget
-			req, err := http.NewRequest("GET", "https://httpbin.org/"+target[len("/httpbin/"):], nil)
-			if err != nil {
-				panic(err)
-			}
-
-			// Just to be explicit:
-			req.Proto = "HTTP/1.1"
-			req.ProtoMajor = 1
-			req.ProtoMinor = 1
-
-			resp, err := client.Do(req)
-			if err != nil {
-				panic(err)
-			}
-			defer resp.Body.Close()
-
-			body, _ := io.ReadAll(resp.Body)
-
-			w.Body = body
-			return
-		}
-
-		if req.RequestLine.RequestTarget == "/video" {
-			w.Status = response.OK
-			w.Headers.Set("Transfer-Encoding", "chunked")
-			w.Headers.Override("content-type", "video/mp4")
-
-			f, err := os.Open("/assets/vim.mp4")
-			if err != nil { /* 404/500 */
-			}
-			defer f.Close()
-
-			buf := make([]byte, 32*1024)
-			for {
-				n, rerr := f.Read(buf)
-				if n > 0 {
-					w.Body = append(w.Body, buf[:n]...)
-				}
-				if rerr == io.EOF {
-					break
-				}
-				if rerr != nil {
-					/* handle read error */
-					break
-				}
-			}
-
-			return
-		}
-
-		w.Status = response.OK
-
-		body := `<html>
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(w, hash), f); err != nil {
+		return err
+	}
+
+	w.Trailers.Set("X-Content-SHA256", hex.EncodeToString(hash.Sum(nil)))
+	return nil
+}
+
+func handleRoot(w *response.Writer, req *request.Request) {
+	w.Headers.Set("content-type", "text/html")
+	w.Status = response.OK
+
+	body := `<html>
   <head>
     <title>200 OK</title>
   </head>
@@ -127,19 +176,5 @@ func main() {
   </body>
 </html>`
 
-		w.SetBody([]byte(body))
-	})
-
-	if err != nil {
-		log.Fatalf("Error starting server: %v", err)
-	}
-
-	defer server.Close()
-	log.Println("Server started on port:", PORT)
-
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	<-sigChan
-
-	log.Println("Server gracefully stopped")
+	w.SetBody([]byte(body))
 }
@@ -5,9 +5,7 @@ import (
 	"httpfromtcp/internal/request"
 	"io"
 	"net"
-	"net/textproto"
 	"os"
-	"sort"
 	"time"
 )
 
@@ -36,7 +34,9 @@ func handleConn(conn net.Conn) {
 	defer conn.Close()
 	_ = conn.SetDeadline(time.Now().Add(5 * time.Second)) // optional safety
 
-	req, err := request.RequestFromReader(conn)
+	reader := request.NewReader(conn)
+	reader.KeepRawHeaders = true // show the headers exactly as sent
+	req, err := reader.ReadRequest()
 	if err != nil {
 		fmt.Println("ERROR: failed to parse request:", err)
 		return
@@ -47,18 +47,11 @@ func handleConn(conn net.Conn) {
 
 	// Print headers
 	fmt.Println("Headers:")
-	if len(req.Headers) == 0 {
+	if len(req.RawHeaders) == 0 {
 		fmt.Println("- (none)")
 	} else {
-		keys := make([]string, 0, len(req.Headers))
-		for k := range req.Headers {
-			keys = append(keys, k)
-		}
-		sort.Strings(keys)
-		for _, k := range keys {
-			v := req.Headers.Get(k)
-			// Canonicalize for display (e.g., "content-type" -> "Content-Type")
-			fmt.Printf("- %s: %s\n", textproto.CanonicalMIMEHeaderKey(k), v)
+		for _, f := range req.RawHeaders {
+			fmt.Printf("- %s: %s\n", f.Name, f.Value)
 		}
 	}
 
@@ -3,10 +3,16 @@ package headers
 import (
 	"bytes"
 	"errors"
+	"iter"
+	"maps"
+	"slices"
 	"strings"
 )
 
-type Headers map[string]string
+// Headers holds field lines keyed by lowercase name. Each line keeps its
+// own value, in the order it was added, so fields that must not be combined
+// (Set-Cookie) survive a round trip.
+type Headers map[string][]string
 
 var (
 	ErrMalformedHeaderLine = errors.New("malformed header-line")
@@ -15,47 +21,88 @@ var (
 	separator = []byte("\r\n")
 )
 
-// Per-line cap; enforce a total cap at a higher layer.
-const maxHeaderLine = 8 * 1024 // 8 KiB
+// DefaultMaxLine is the per-line cap Parse uses; the request parser
+// enforces the caps on the whole section (see request.Limits).
+const DefaultMaxLine = 8 * 1024 // 8 KiB
 
 func NewHeaders() Headers { return Headers{} }
 
-// Get should be case-insensitive.
+// Get returns the combined value of all name lines, joined with commas
+// (RFC 9110 5.3), or "" if there are none. Lookup is case-insensitive.
+// Use Values for fields that cannot be combined, like Set-Cookie.
 func (h Headers) Get(name string) string {
+	return strings.Join(h[strings.ToLower(name)], ",")
+}
+
+// Values returns each name line's value in the order they were added.
+// The slice belongs to h.
+func (h Headers) Values(name string) []string {
 	return h[strings.ToLower(name)]
 }
 
-func (h Headers) Delete(name string) {
-	delete(h, strings.ToLower(name))
+// Add appends a name line, keeping any existing ones.
+func (h Headers) Add(name, value string) {
+	name = strings.ToLower(name)
+	h[name] = append(h[name], value)
 }
 
+// Set replaces all name lines with a single one.
 func (h Headers) Set(name, value string) {
-	name = strings.ToLower(name)
+	h[strings.ToLower(name)] = []string{value}
+}
+
+func (h Headers) Del(name string) {
+	delete(h, strings.ToLower(name))
+}
 
-	if old, ok := h[name]; ok {
-		h[name] = old + "," + value
-	} else {
-		h[name] = value
+// All yields every field line as (lowercase name, value), names in sorted
+// order and the lines of one name in the order they were added.
+func (h Headers) All() iter.Seq2[string, string] {
+	return func(yield func(string, string) bool) {
+		for _, name := range slices.Sorted(maps.Keys(h)) {
+			for _, v := range h[name] {
+				if !yield(name, v) {
+					return
+				}
+			}
+		}
 	}
 }
 
-func (h Headers) Override(name, value string) {
-	name = strings.ToLower(name)
-	h[name] = value
+// HasToken reports whether the comma-separated field name contains token,
+// compared case-insensitively (e.g. "Connection: keep-alive, Close").
+func (h Headers) HasToken(name, token string) bool {
+	for _, v := range h.Values(name) {
+		for t := range strings.SplitSeq(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 func (h Headers) Parse(data []byte) (n int, done bool, err error) {
+	return ParseFields(data, DefaultMaxLine, h.Add)
+}
+
+// ParseFields reads field lines from data up to and including the blank
+// line ending the section, calling add with each name as sent and its
+// trimmed value. Lines longer than maxLine fail with ErrHeaderLineTooLong.
+// It returns the bytes consumed; lines already passed to add are consumed
+// even when it needs more data.
+func ParseFields(data []byte, maxLine int, add func(name, value string)) (n int, done bool, err error) {
 	off := 0
 	for {
 		idx := bytes.Index(data[off:], separator)
 		if idx == -1 {
 			// If current unterminated line exceeds cap, fail (prevents DoS).
-			if len(data)-off > maxHeaderLine {
+			if len(data)-off > maxLine {
 				return 0, false, ErrHeaderLineTooLong
 			}
 			return off, false, nil // need more bytes
 		}
-		if idx > maxHeaderLine {
+		if idx > maxLine {
 			return 0, false, ErrHeaderLineTooLong
 		}
 
@@ -85,16 +132,15 @@ func (h Headers) Parse(data []byte) (n int, done bool, err error) {
 			return 0, false, ErrMalformedHeaderLine
 		}
 
-		// Validate token and normalize to lowercase (HTTP/2-friendly)
+		// Validate token; callers decide whether to normalize its case
 		if !isTokenTable(nameRaw) {
 			return 0, false, ErrMalformedHeaderLine
 		}
-		name := strings.ToLower(string(nameRaw))
 
 		// Trim optional whitespace around the value
 		val := strings.Trim(string(line[colon+1:]), " \t")
 
-		h.Set(name, val)
+		add(string(nameRaw), val)
 	}
 }
 
@@ -115,12 +161,37 @@ func init() {
 	}
 }
 
-func isTokenTable(b []byte) bool {
+func isTokenTable[T string | []byte](b T) bool {
 	if len(b) == 0 {
 		return false
 	}
-	for _, c := range b {
-		if c > 127 || !allowed[c] {
+	for i := 0; i < len(b); i++ {
+		if c := b[i]; c > 127 || !allowed[c] {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidName reports whether name is a valid field name (an RFC 9110 token).
+func ValidName(name string) bool {
+	return isTokenTable(name)
+}
+
+// ValidValue reports whether value matches the RFC 9110 field-value
+// grammar: visible characters, obs-text and inner SP/HTAB only. CR, LF,
+// NUL and other controls are rejected, and so is leading or trailing
+// whitespace.
+func ValidValue(value string) bool {
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c == ' ' || c == '\t' {
+			if i == 0 || i == len(value)-1 {
+				return false
+			}
+			continue
+		}
+		if c < 0x21 || c == 0x7f {
 			return false
 		}
 	}
@@ -53,7 +53,7 @@ func TestRequestHeadersParsing(t *testing.T) {
 	require.Error(t, err)
 
 	// Long line without CRLF => ErrHeaderLineTooLong
-	big := bytes.Repeat([]byte("A"), maxHeaderLine+1)
+	big := bytes.Repeat([]byte("A"), DefaultMaxLine+1)
 	_, _, err = NewHeaders().Parse(append(big, 'B'))
 	require.ErrorIs(t, err, ErrHeaderLineTooLong)
 
@@ -64,3 +64,85 @@ func TestRequestHeadersParsing(t *testing.T) {
 	assert.True(t, done)
 	assert.Equal(t, "accept,encoding", h.Get("Vary"))
 }
+
+func TestHeadersHasToken(t *testing.T) {
+	h := NewHeaders()
+	h.Set("Connection", "keep-alive, Close")
+	h.Add("Connection", "Upgrade")
+	assert.True(t, h.HasToken("connection", "close"))
+	assert.True(t, h.HasToken("CONNECTION", "upgrade"))
+	assert.False(t, h.HasToken("connection", "clo"))
+	assert.False(t, h.HasToken("te", "trailers"))
+}
+
+func TestHeadersMultiValue(t *testing.T) {
+	// Test: Parse keeps each line separately, Get combines them
+	h := NewHeaders()
+	_, done, err := h.Parse([]byte("Set-Cookie: a=1; Path=/\r\nHost: x\r\nset-cookie: b=2, c\r\n\r\n"))
+	require.NoError(t, err)
+	require.True(t, done)
+	assert.Equal(t, []string{"a=1; Path=/", "b=2, c"}, h.Values("SET-COOKIE"))
+	assert.Equal(t, "a=1; Path=/,b=2, c", h.Get("set-cookie"))
+	assert.Nil(t, h.Values("missing"))
+
+	// Test: Add appends, Set replaces, Del removes
+	h.Add("Set-Cookie", "d=4")
+	assert.Len(t, h.Values("set-cookie"), 3)
+	h.Set("Set-Cookie", "e=5")
+	assert.Equal(t, []string{"e=5"}, h.Values("set-cookie"))
+	h.Del("Set-Cookie")
+	assert.Empty(t, h.Get("set-cookie"))
+
+	// Test: All yields lines by sorted name, values in insertion order
+	h = NewHeaders()
+	h.Add("Vary", "accept")
+	h.Add("Accept", "*/*")
+	h.Add("Vary", "encoding")
+	var lines []string
+	for k, v := range h.All() {
+		lines = append(lines, k+": "+v)
+	}
+	assert.Equal(t, []string{"accept: */*", "vary: accept", "vary: encoding"}, lines)
+}
+
+func TestRawHeaders(t *testing.T) {
+	var raw RawHeaders
+	data := []byte("Host: localhost\r\nX-API-Key: k1\r\nx-api-key: k2\r\n\r\n")
+	n, done, err := raw.Parse(data)
+	require.NoError(t, err)
+	assert.True(t, done)
+	assert.Equal(t, len(data), n)
+	assert.Equal(t, RawHeaders{
+		{Name: "Host", Value: "localhost"},
+		{Name: "X-API-Key", Value: "k1"},
+		{Name: "x-api-key", Value: "k2"},
+	}, raw)
+	assert.Equal(t, []string{"k1", "k2"}, raw.Values("X-Api-Key"))
+
+	h := raw.Headers()
+	assert.Equal(t, "localhost", h.Get("host"))
+	assert.Equal(t, []string{"k1", "k2"}, h.Values("x-api-key"))
+
+	// Invalid lines fail the same way as Headers.Parse
+	raw = nil
+	_, _, err = raw.Parse([]byte("Bad Name: x\r\n\r\n"))
+	require.ErrorIs(t, err, ErrMalformedHeaderLine)
+}
+
+func TestValidNameValue(t *testing.T) {
+	assert.True(t, ValidName("X-Request-ID"))
+	assert.False(t, ValidName(""))
+	assert.False(t, ValidName("Bad Name"))
+	assert.False(t, ValidName("X-Evil\r\nSet-Cookie"))
+
+	assert.True(t, ValidValue(""))
+	assert.True(t, ValidValue("text/html; charset=utf-8"))
+	assert.True(t, ValidValue("a\tb c"))
+	assert.True(t, ValidValue("caf\xc3\xa9")) // obs-text
+	assert.False(t, ValidValue("ok\r\nSet-Cookie: evil=1"))
+	assert.False(t, ValidValue("line\nbreak"))
+	assert.False(t, ValidValue("nul\x00"))
+	assert.False(t, ValidValue("del\x7f"))
+	assert.False(t, ValidValue(" padded"))
+	assert.False(t, ValidValue("padded\t"))
+}
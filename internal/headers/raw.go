@@ -0,0 +1,42 @@
+package headers
+
+import "strings"
+
+// Field is one field line with its name spelled as the peer sent it.
+type Field struct {
+	Name  string
+	Value string
+}
+
+// RawHeaders records field lines in their original order and name casing,
+// for inspection tools and for forwarding a message unchanged. Headers is
+// the normalized view used everywhere else.
+type RawHeaders []Field
+
+// Parse works like Headers.Parse, appending each field line to r as-is.
+func (r *RawHeaders) Parse(data []byte) (n int, done bool, err error) {
+	return ParseFields(data, DefaultMaxLine, func(name, value string) {
+		*r = append(*r, Field{Name: name, Value: value})
+	})
+}
+
+// Values returns the values of every name line in order, matching names
+// case-insensitively.
+func (r RawHeaders) Values(name string) []string {
+	var vals []string
+	for _, f := range r {
+		if strings.EqualFold(f.Name, name) {
+			vals = append(vals, f.Value)
+		}
+	}
+	return vals
+}
+
+// Headers returns the normalized view of r.
+func (r RawHeaders) Headers() Headers {
+	h := NewHeaders()
+	for _, f := range r {
+		h.Add(f.Name, f.Value)
+	}
+	return h
+}
@@ -0,0 +1,106 @@
+package request
+
+import (
+	"errors"
+	"io"
+)
+
+var ErrBodyReadAfterClose = errors.New("http: read on closed request body")
+
+// bodyReader decodes a streamed request body straight from the connection.
+// It shares the connection Reader's buffer, so it starts with whatever
+// bytes were already pulled past the header section, and whatever it reads
+// past the end of the body is left there for the next request.
+type bodyReader struct {
+	req    *Request
+	conn   *Reader
+	closed bool
+}
+
+func (b *bodyReader) Read(p []byte) (int, error) {
+	if b.closed {
+		return 0, ErrBodyReadAfterClose
+	}
+	return b.read(p)
+}
+
+// read is Read without the closed check, so the connection can still skip
+// a body the handler closed early.
+func (b *bodyReader) read(p []byte) (int, error) {
+	if b.req.error() {
+		return 0, b.req.parseErr
+	}
+	if b.req.done() || len(p) == 0 {
+		return 0, io.EOF
+	}
+
+	c := b.conn
+	for {
+		if len(c.buf) > 0 {
+			written := 0
+			n, done, err := b.req.parseBody(c.buf, len(p), func(chunk []byte) {
+				written += copy(p[written:], chunk)
+			})
+			if err != nil {
+				return written, b.req.setErr(err)
+			}
+
+			// Shift leftover (undecoded) data down to front of buffer
+			copy(c.buf, c.buf[n:])
+			c.buf = c.buf[:len(c.buf)-n]
+
+			if done {
+				b.req.state = RequestDone
+				if written == 0 {
+					return 0, io.EOF
+				}
+			}
+			if written > 0 || done {
+				return written, nil
+			}
+		}
+
+		n, err := c.src.Read(c.tmp)
+		c.buf = append(c.buf, c.tmp[:n]...)
+		if err != nil {
+			if err == io.EOF && n > 0 {
+				continue // decode what we got; the next read will see EOF again
+			}
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF // body cut short
+			}
+			return 0, b.req.setErr(err)
+		}
+	}
+}
+
+// Close marks the body as consumed from the handler's point of view.
+// Unread bytes stay on the wire.
+func (b *bodyReader) Close() error {
+	b.closed = true
+	return nil
+}
+
+// DiscardBody skips up to limit bytes of the last streamed body that were
+// left unread. It reports whether the body ended within limit (or there was
+// nothing to skip), i.e. whether the next request can be read.
+func (cr *Reader) DiscardBody(limit int64) bool {
+	b := cr.body
+	if b == nil {
+		return true // buffered bodies are consumed during ReadRequest
+	}
+
+	p := make([]byte, 4*1024)
+	var skipped int64
+	for !b.req.done() {
+		if b.req.error() || skipped > limit {
+			return false
+		}
+		n, err := b.read(p)
+		skipped += int64(n)
+		if err != nil && err != io.EOF {
+			return false
+		}
+	}
+	return skipped <= limit
+}
@@ -0,0 +1,52 @@
+package request
+
+import (
+	"errors"
+	"httpfromtcp/internal/headers"
+)
+
+// ParseError is a failure to read a request, with the status code a server
+// should answer it with. It wraps the underlying error, so errors.Is still
+// matches the sentinels (ErrMessageTooLarge, ...).
+type ParseError struct {
+	Err    error
+	Status int // e.g. 431 for ErrHeaderLineTooLong; 400 when nothing fits better
+}
+
+func (e *ParseError) Error() string { return e.Err.Error() }
+
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// errorStatus maps parse errors to more specific statuses than 400.
+var errorStatus = []struct {
+	err    error
+	status int
+}{
+	{ErrMessageTooLarge, 413},           // Content Too Large
+	{ErrRequestLineTooLong, 414},        // URI Too Long
+	{headers.ErrHeaderLineTooLong, 431}, // Request Header Fields Too Large
+	{ErrHeaderTooLarge, 431},            // Request Header Fields Too Large
+	{ErrTooManyHeaders, 431},            // Request Header Fields Too Large
+	{ErrUnsupportedTE, 501},             // Not Implemented
+	{ErrUnsupportedHTTPMethod, 501},     // Not Implemented
+	{ErrUnsupportedHTTPVersion, 505},    // HTTP Version Not Supported
+}
+
+func newParseError(err error) *ParseError {
+	for _, e := range errorStatus {
+		if errors.Is(err, e.err) {
+			return &ParseError{Err: err, Status: e.status}
+		}
+	}
+	return &ParseError{Err: err, Status: 400}
+}
+
+// ErrorStatus returns the status code to answer a request that failed to
+// parse with err, or 400 if err carries none.
+func ErrorStatus(err error) int {
+	var perr *ParseError
+	if errors.As(err, &perr) {
+		return perr.Status
+	}
+	return 400
+}
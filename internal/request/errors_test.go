@@ -0,0 +1,43 @@
+package request
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorStatus(t *testing.T) {
+	long := strings.Repeat("a", DefaultLimits.MaxStartLine)
+	cases := []struct {
+		name   string
+		input  string
+		status int
+	}{
+		{"malformed", "GET /\r\n\r\n", 400},
+		{"body too large", "POST / HTTP/1.1\r\nContent-Length: 99999999999\r\n\r\n", 413},
+		{"start-line too long", "GET /" + long, 414},
+		{"whole start-line too long", "GET /" + long + " HTTP/1.1\r\n\r\n", 414},
+		{"header line too long", "GET / HTTP/1.1\r\nX-Big: " + long + "\r\n", 431},
+		{"unsupported transfer-encoding", "POST / HTTP/1.1\r\nTransfer-Encoding: gzip\r\n\r\n", 501},
+		{"unknown method", "BREW / HTTP/1.1\r\n\r\n", 501},
+		{"unsupported version", "GET / HTTP/2.0\r\n\r\n", 505},
+	}
+	for _, c := range cases {
+		_, err := RequestFromReader(strings.NewReader(c.input))
+		require.Error(t, err, c.name)
+		assert.Equal(t, c.status, ErrorStatus(err), c.name)
+	}
+
+	// Test: The sentinel is still reachable through the ParseError
+	_, err := RequestFromReader(strings.NewReader("GET / HTTP/2.0\r\n\r\n"))
+	require.ErrorIs(t, err, ErrUnsupportedHTTPVersion)
+	var perr *ParseError
+	require.ErrorAs(t, err, &perr)
+	assert.Equal(t, 505, perr.Status)
+
+	// Test: Errors that are not parse errors get 400
+	assert.Equal(t, 400, ErrorStatus(errors.New("boom")))
+}
@@ -0,0 +1,81 @@
+package request
+
+import (
+	"bytes"
+	"errors"
+	"httpfromtcp/internal/headers"
+)
+
+var (
+	ErrHeaderTooLarge = errors.New("header section too large")
+	ErrTooManyHeaders = errors.New("too many header fields")
+)
+
+// Limits caps how much of a request the parser accepts. A zero field means
+// "use the default" (see DefaultLimits).
+type Limits struct {
+	MaxStartLine   int   // request-line bytes, excluding CRLF (414)
+	MaxHeaderLine  int   // bytes in one header field line (431)
+	MaxHeaderBytes int   // bytes in the whole header section (431)
+	MaxHeaderCount int   // header field lines (431)
+	MaxBodyBytes   int64 // body bytes, buffered requests only (413)
+}
+
+// DefaultLimits are used for every field a Limits leaves at zero.
+var DefaultLimits = Limits{
+	MaxStartLine:   8 * 1024,               // 8 KiB
+	MaxHeaderLine:  headers.DefaultMaxLine, // 8 KiB
+	MaxHeaderBytes: 64 * 1024,              // 64 KiB
+	MaxHeaderCount: 100,
+	MaxBodyBytes:   10 * 1024 * 1024, // 10 MiB
+}
+
+// or returns l with its zero fields taken from d.
+func (l Limits) or(d Limits) Limits {
+	if l.MaxStartLine <= 0 {
+		l.MaxStartLine = d.MaxStartLine
+	}
+	if l.MaxHeaderLine <= 0 {
+		l.MaxHeaderLine = d.MaxHeaderLine
+	}
+	if l.MaxHeaderBytes <= 0 {
+		l.MaxHeaderBytes = d.MaxHeaderBytes
+	}
+	if l.MaxHeaderCount <= 0 {
+		l.MaxHeaderCount = d.MaxHeaderCount
+	}
+	if l.MaxBodyBytes <= 0 {
+		l.MaxBodyBytes = d.MaxBodyBytes
+	}
+	return l
+}
+
+// checkLimits reports whether what was read of the start-line and header
+// section so far, plus pending bytes of an unfinished header line, fits l.
+func (r *Request) checkLimits(l Limits, pending int) error {
+	switch {
+	case r.startLineLen > l.MaxStartLine:
+		return ErrRequestLineTooLong
+	case r.longestHeader > l.MaxHeaderLine:
+		return headers.ErrHeaderLineTooLong
+	case r.headerBytes+pending > l.MaxHeaderBytes:
+		return ErrHeaderTooLarge
+	case r.headerCount > l.MaxHeaderCount:
+		return ErrTooManyHeaders
+	}
+	return nil
+}
+
+// longestLine returns the length of the longest CRLF-terminated line in p.
+func longestLine(p []byte) int {
+	longest := 0
+	for len(p) > 0 {
+		i := bytes.Index(p, separator)
+		if i == -1 {
+			break
+		}
+		longest = max(longest, i)
+		p = p[i+len(separator):]
+	}
+	return longest
+}
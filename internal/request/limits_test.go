@@ -0,0 +1,91 @@
+package request
+
+import (
+	"httpfromtcp/internal/headers"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readWithLimits(input string, l Limits, limitsFor func(*Request) Limits) (*Request, error) {
+	cr := NewReader(&chunkReader{data: input, numBytesPerRead: 7})
+	cr.Limits = l
+	cr.LimitsFor = limitsFor
+	return cr.ReadRequest()
+}
+
+func TestLimits(t *testing.T) {
+	req := "POST /upload HTTP/1.1\r\nHost: localhost\r\nX-A: 1\r\nX-B: 2\r\nContent-Length: 5\r\n\r\nhello"
+
+	// Test: Defaults accept an ordinary request
+	r, err := readWithLimits(req, Limits{}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(r.Body))
+
+	// Test: Each limit rejects with its own error
+	_, err = readWithLimits(req, Limits{MaxStartLine: 10}, nil)
+	require.ErrorIs(t, err, ErrRequestLineTooLong)
+	_, err = readWithLimits(req, Limits{MaxHeaderLine: 10}, nil)
+	require.ErrorIs(t, err, headers.ErrHeaderLineTooLong)
+	_, err = readWithLimits(req, Limits{MaxHeaderBytes: 40}, nil)
+	require.ErrorIs(t, err, ErrHeaderTooLarge)
+	_, err = readWithLimits(req, Limits{MaxHeaderCount: 3}, nil)
+	require.ErrorIs(t, err, ErrTooManyHeaders)
+	assert.Equal(t, 431, ErrorStatus(err))
+	_, err = readWithLimits(req, Limits{MaxBodyBytes: 4}, nil)
+	require.ErrorIs(t, err, ErrMessageTooLarge)
+	_, err = RequestFromReaderLimits(strings.NewReader(req), Limits{MaxBodyBytes: 4})
+	require.ErrorIs(t, err, ErrMessageTooLarge)
+
+	// Test: Exactly at the limits is fine
+	_, err = readWithLimits(req, Limits{MaxStartLine: len("POST /upload HTTP/1.1"), MaxHeaderCount: 4, MaxBodyBytes: 5}, nil)
+	require.NoError(t, err)
+
+	// Test: A header section that never ends is cut off by MaxHeaderBytes
+	_, err = readWithLimits("GET / HTTP/1.1\r\n"+strings.Repeat("X-Pad: "+strings.Repeat("a", 1000)+"\r\n", 100), Limits{}, nil)
+	require.ErrorIs(t, err, ErrHeaderTooLarge)
+
+	// Test: LimitsFor can raise the body cap and tighten header limits
+	perRoute := func(r *Request) Limits {
+		if r.RequestLine.Target.Path == "/upload" {
+			return Limits{MaxBodyBytes: 5}
+		}
+		return Limits{MaxHeaderCount: 1}
+	}
+	r, err = readWithLimits(req, Limits{MaxBodyBytes: 1}, perRoute)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(r.Body))
+	_, err = readWithLimits(strings.Replace(req, "/upload", "/other", 1), Limits{}, perRoute)
+	require.ErrorIs(t, err, ErrTooManyHeaders)
+}
+
+func TestTrailerLimits(t *testing.T) {
+	chunked := "POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n3\r\nabc\r\n0\r\n"
+
+	// Test: Too many trailer lines
+	_, err := readWithLimits(chunked+strings.Repeat("X-T: 1\r\n", 200_000)+"\r\n", Limits{}, nil)
+	require.ErrorIs(t, err, ErrTooManyHeaders)
+	assert.Equal(t, 431, ErrorStatus(err))
+
+	// Test: Trailer section larger than MaxHeaderBytes
+	_, err = readWithLimits(chunked+strings.Repeat("X-T: "+strings.Repeat("a", 1000)+"\r\n", 50)+"\r\n",
+		Limits{MaxHeaderBytes: 16 * 1024}, nil)
+	require.ErrorIs(t, err, ErrHeaderTooLarge)
+	assert.Equal(t, 431, ErrorStatus(err))
+
+	// Test: Streamed bodies hit the same limits
+	cr := NewReader(strings.NewReader(chunked + strings.Repeat("X-T: 1\r\n", 200) + "\r\n"))
+	r, err := cr.StreamRequest()
+	require.NoError(t, err)
+	_, err = io.ReadAll(r.BodyReader)
+	require.ErrorIs(t, err, ErrTooManyHeaders)
+
+	// Test: Trailers within the limits are kept
+	r, err = readWithLimits(chunked+"X-Sum: 42\r\n\r\n", Limits{}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "abc", string(r.Body))
+	assert.Equal(t, "42", r.Trailers.Get("x-sum"))
+}
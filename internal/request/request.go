@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"httpfromtcp/internal/headers"
 	"io"
+	"math"
 	"strconv"
 	"strings"
 )
@@ -16,10 +17,79 @@ type Request struct {
 	RequestLine *RequestLine
 	Headers     headers.Headers
 	Body        []byte
-	state       RequestState // 1 = initialized, 2 = parsing_headers, 3 = parsing_body, 4 = done, 5 = error
-	parseErr    error
+	Trailers    headers.Headers // fields sent after the last chunk of a chunked body
+
+	// RawHeaders keeps the header lines as sent (order and name casing);
+	// nil unless the Reader's KeepRawHeaders is set.
+	RawHeaders headers.RawHeaders
+
+	// BodyReader yields the decoded body. For buffered requests it reads
+	// from Body; for streamed requests it pulls from the connection.
+	BodyReader io.ReadCloser
+
+	// RemoteAddr is the client's "host:port", set by the server.
+	RemoteAddr string
+
+	// Params holds path parameters captured by the router (e.g. "id" for
+	// "/users/{id}"); nil when the request was not routed.
+	Params map[string]string
+
+	state    RequestState // 1 = initialized, 2 = parsing_headers, 3 = parsing_body, 4 = done, 5 = error
+	parseErr error
+
+	// streaming leaves the body on the wire for BodyReader instead of
+	// buffering it into Body (and lifts the MaxBodyBytes cap).
+	streaming bool
+
+	// keepRaw also records header lines into RawHeaders.
+	keepRaw bool
+
+	// limits caps this request; limitsFor, if set, picks the limits for
+	// the rest of it once the header section is in (see Reader.LimitsFor).
+	limits    Limits
+	limitsFor func(*Request) Limits
+
+	// What the start-line and header section took, checked against limits.
+	startLineLen  int
+	headerBytes   int
+	headerCount   int
+	longestHeader int
+
+	// Same for the trailer section, which gets the header limits too.
+	trailerBytes int
+	trailerCount int
+
+	// Body framing, decided once at the end of the header section.
+	framing  bodyFraming
+	bodyWant int // Content-Length bodies only
+	bodyRead int // decoded body bytes so far
+
+	// Chunked decoding progress (framing == bodyChunked).
+	chunkState chunkState
+	chunkLeft  int // data bytes still expected in the current chunk
 }
 
+// bodyFraming tells how the message body is delimited on the wire.
+type bodyFraming int
+
+const (
+	bodyNone          bodyFraming = iota // no body
+	bodyContentLength                    // exactly Content-Length bytes
+	bodyChunked                          // Transfer-Encoding: chunked
+)
+
+// chunkState tracks where we are inside a chunked body:
+//
+//	chunk-size [ chunk-ext ] CRLF chunk-data CRLF ... 0 CRLF trailer-section CRLF
+type chunkState int
+
+const (
+	chunkSize    chunkState = iota // expecting a chunk-size line
+	chunkData                      // inside chunk-data
+	chunkDataEnd                   // expecting the CRLF after chunk-data
+	chunkTrailer                   // after the last chunk; reading trailer fields
+)
+
 type RequestState int
 
 const (
@@ -44,18 +114,25 @@ var RequestStateName = map[RequestState]string{
 //	<method> <request-target> <HTTP-version>
 type RequestLine struct {
 	HTTPVersion   string
-	RequestTarget string
+	RequestTarget string // as sent
 	Method        string
+	Target        *Target // RequestTarget parsed
 }
 
 // Predefined errors for different validation failures.
 var (
 	ErrMalformedRequestLine   = errors.New("malformed request-line")
+	ErrRequestLineTooLong     = errors.New("request-line too long")
 	ErrUnsupportedHTTPVersion = errors.New("unsupported http version")
 	ErrUnsupportedHTTPMethod  = errors.New("unsupported http method")
 	ErrMissingRequestTarget   = errors.New("missing request target")
 	ErrMessageTooLarge        = errors.New("http message exceeds drain limit")
 	ErrRequestBodyExceedsCL   = errors.New("http body exceeds content length")
+	ErrMalformedChunk         = errors.New("malformed chunked encoding")
+	ErrChunkLineTooLong       = errors.New("chunk-size line too long")
+	ErrUnsupportedTE          = errors.New("unsupported transfer-encoding")
+	ErrConflictingFraming     = errors.New("both transfer-encoding and content-length present")
+	ErrBodyNotConsumed        = errors.New("previous request body not fully read")
 
 	// Precompiled regexes for supported methods and version.
 	// methodRE  = regexp.MustCompile(`^(GET|HEAD|POST|PUT|DELETE|CONNECT|OPTIONS|TRACE|PATCH)$`)
@@ -71,74 +148,267 @@ var (
 	separator = []byte("\r\n")
 )
 
-// Maximum allowed size of the start-line, per RFC 9112 recommendations.
-// Prevents DoS attacks from sending extremely long lines.
-const maxStartLine = 8 * 1024         // 8 KiB cap
-const maxBodyBytes = 10 * 1024 * 1024 // 10 MiB
+// Cap on a single chunk-size line (size + extensions).
+const maxChunkLine = 4 * 1024 // 4 KiB
 
 // newRequest initializes a Request in state=Initialized (ready to parse).
 func newRequest() *Request {
 	return &Request{
-		state:   RequestInitialized,
-		Headers: headers.NewHeaders(), // <-- initialize to avoid panic
+		state:    RequestInitialized,
+		Headers:  headers.NewHeaders(), // <-- initialize to avoid panic
+		Trailers: headers.NewHeaders(),
+		limits:   DefaultLimits,
 	}
 }
 
-// done reports whether we have completed the start-line phase.
-// We stop the outer read loop once we reach ParsingHeaders (or Done).
+// Param returns the path parameter captured under name, or "".
+func (r *Request) Param(name string) string {
+	return r.Params[name]
+}
+
+// done reports whether the whole request (incl. body) has been parsed.
 func (r *Request) done() bool {
 	return r.state == RequestDone
 }
 
+// ready reports whether the outer read loop can hand the request out:
+// fully parsed, or (when streaming) headers parsed and body still on the wire.
+func (r *Request) ready() bool {
+	return r.done() || (r.streaming && r.state == RequestParsingBody)
+}
+
 func (r *Request) error() bool {
 	return r.state == RequestError
 }
 
 func (r *Request) setErr(err error) error {
-	r.parseErr = err
+	r.parseErr = newParseError(err)
 	r.state = RequestError
-	return err
+	return r.parseErr
 }
 
-// hasBody inspects headers and tells whether the request has a body,
-// and if so, how many bytes are expected (via Content-Length).
-// It currently does NOT support chunked TE; returns an error in that case.
+// bodyFraming inspects headers and tells how the request body is delimited,
+// and for Content-Length bodies, how many bytes are expected.
 //
 // Returns:
 //
-//	has  = true iff there is a body with positive Content-Length
-//	want = exact number of body bytes to read when has==true
-//	err  = framing/size errors (e.g., bad CL, chunked TE, too large)
-func (r *Request) hasBody() (has bool, want int, err error) {
+//	framing = bodyNone, bodyContentLength or bodyChunked
+//	want    = exact number of body bytes to read when framing==bodyContentLength
+//	err     = framing/size errors (e.g., bad CL, unknown TE, too large)
+func (r *Request) bodyFraming() (framing bodyFraming, want int, err error) {
 	te := strings.ToLower(strings.TrimSpace(r.Headers.Get("transfer-encoding")))
 	if te != "" {
-		// You can broaden this if you later implement chunked.
-		if strings.Contains(te, "chunked") {
-			return false, 0, fmt.Errorf("transfer-encoding: chunked not supported")
+		// TE and CL together is a classic smuggling vector; refuse it (RFC 9112 6.3).
+		if r.Headers.Get("content-length") != "" {
+			return bodyNone, 0, ErrConflictingFraming
+		}
+		// Only plain "chunked" is supported; compressions etc. are not.
+		if te != "chunked" {
+			return bodyNone, 0, fmt.Errorf("%w: %q", ErrUnsupportedTE, te)
 		}
-		// Any TE without chunked is unsupported in this simple parser
-		return false, 0, fmt.Errorf("unsupported transfer-encoding: %q", te)
+		return bodyChunked, 0, nil
 	}
 
 	clStr := strings.TrimSpace(r.Headers.Get("content-length"))
 	if clStr == "" {
 		// No TE, no CL => no body for requests (HTTP/1.1)
-		return false, 0, nil
+		return bodyNone, 0, nil
 	}
 
 	cl, perr := strconv.ParseInt(clStr, 10, 64)
 	if perr != nil || cl < 0 {
-		return false, 0, fmt.Errorf("bad Content-Length: %q", clStr)
+		return bodyNone, 0, fmt.Errorf("bad Content-Length: %q", clStr)
 	}
 
 	if cl == 0 {
-		return false, 0, nil
+		return bodyNone, 0, nil
+	}
+
+	if !r.streaming && cl > r.limits.MaxBodyBytes {
+		return bodyNone, 0, ErrMessageTooLarge
+	}
+	return bodyContentLength, int(cl), nil
+}
+
+// parseBody decodes body bytes from data according to r.framing, handing
+// at most limit decoded bytes to emit.
+// Returns bytes of data consumed and whether the body (incl. trailers) is done.
+func (r *Request) parseBody(data []byte, limit int, emit func([]byte)) (n int, done bool, err error) {
+	if r.framing == bodyChunked {
+		return r.parseChunked(data, limit, emit)
+	}
+
+	if r.bodyRead > r.bodyWant {
+		return 0, false, ErrRequestBodyExceedsCL
+	}
+
+	// Consume up to remaining bytes from data
+	toRead := min(r.bodyWant-r.bodyRead, len(data), limit)
+	if toRead > 0 {
+		emit(data[:toRead])
+		r.bodyRead += toRead
+	}
+	return toRead, r.bodyRead == r.bodyWant, nil
+}
+
+func (r *Request) appendBody(p []byte) {
+	r.Body = append(r.Body, p...)
+}
+
+// parseChunked decodes as much of a chunked body from data as possible,
+// handing up to limit bytes of chunk-data to emit and parsing trailer
+// fields into r.Trailers.
+// Returns bytes consumed, whether the whole body (incl. trailers) is done,
+// and any framing error.
+func (r *Request) parseChunked(data []byte, limit int, emit func([]byte)) (n int, done bool, err error) {
+	emitted := 0
+	for {
+		rest := data[n:]
+		switch r.chunkState {
+		case chunkSize:
+			idx := bytes.Index(rest, separator)
+			if idx == -1 {
+				if len(rest) > maxChunkLine {
+					return 0, false, ErrChunkLineTooLong
+				}
+				return n, false, nil // need more bytes
+			}
+			if idx > maxChunkLine {
+				return 0, false, ErrChunkLineTooLong
+			}
+
+			size, perr := parseChunkSize(rest[:idx])
+			if perr != nil {
+				return 0, false, perr
+			}
+			if !r.streaming && int64(r.bodyRead)+size > r.limits.MaxBodyBytes {
+				return 0, false, ErrMessageTooLarge
+			}
+			n += idx + len(separator)
+
+			if size == 0 {
+				r.chunkState = chunkTrailer
+				continue
+			}
+			r.chunkLeft = int(size)
+			r.chunkState = chunkData
+
+		case chunkData:
+			toRead := min(r.chunkLeft, len(rest), limit-emitted)
+			if toRead == 0 {
+				return n, false, nil // need more bytes (or no room to emit)
+			}
+			emit(rest[:toRead])
+			emitted += toRead
+			r.bodyRead += toRead
+			r.chunkLeft -= toRead
+			n += toRead
+			if r.chunkLeft == 0 {
+				r.chunkState = chunkDataEnd
+			}
+
+		case chunkDataEnd:
+			if len(rest) < len(separator) {
+				return n, false, nil
+			}
+			if !bytes.HasPrefix(rest, separator) {
+				return 0, false, ErrMalformedChunk
+			}
+			n += len(separator)
+			r.chunkState = chunkSize
+
+		case chunkTrailer:
+			// Trailer section has the same grammar as the header section.
+			tn, end, terr := r.parseTrailers(rest)
+			if terr != nil {
+				return 0, false, terr
+			}
+			n += tn
+			return n, end, nil
+		}
+	}
+}
+
+// parseHeaders feeds data to r.Headers, and to r.RawHeaders as well when
+// raw lines are kept, enforcing the header limits.
+func (r *Request) parseHeaders(data []byte) (n int, done bool, err error) {
+	n, done, err = headers.ParseFields(data, r.limits.MaxHeaderLine, func(name, value string) {
+		r.headerCount++
+		r.Headers.Add(name, value)
+		if r.keepRaw {
+			r.RawHeaders = append(r.RawHeaders, headers.Field{Name: name, Value: value})
+		}
+	})
+	if err != nil {
+		return 0, false, err
+	}
+
+	r.headerBytes += n
+	r.longestHeader = max(r.longestHeader, longestLine(data[:n]))
+	pending := 0
+	if !done {
+		pending = len(data) - n // a line still arriving counts too
+	}
+	if err := r.checkLimits(r.limits, pending); err != nil {
+		return 0, false, err
+	}
+	return n, done, nil
+}
+
+// parseTrailers feeds data to r.Trailers, holding the trailer section to
+// the same line, size and count limits as the header section.
+func (r *Request) parseTrailers(data []byte) (n int, done bool, err error) {
+	n, done, err = headers.ParseFields(data, r.limits.MaxHeaderLine, func(name, value string) {
+		r.trailerCount++
+		r.Trailers.Add(name, value)
+	})
+	if err != nil {
+		return 0, false, err
+	}
+
+	r.trailerBytes += n
+	pending := 0
+	if !done {
+		pending = len(data) - n
+	}
+	switch {
+	case r.trailerBytes+pending > r.limits.MaxHeaderBytes:
+		return 0, false, ErrHeaderTooLarge
+	case r.trailerCount > r.limits.MaxHeaderCount:
+		return 0, false, ErrTooManyHeaders
+	}
+	return n, done, nil
+}
+
+// parseChunkSize parses "chunk-size [ chunk-ext ]" (without CRLF) and
+// returns the size. Extensions are validated loosely and ignored.
+func parseChunkSize(line []byte) (int64, error) {
+	sizeRaw := line
+	if semi := bytes.IndexByte(line, ';'); semi != -1 {
+		sizeRaw = line[:semi]
+	}
+	// BWS is allowed between chunk-size and ';'
+	sizeRaw = bytes.TrimRight(sizeRaw, " \t")
+	if len(sizeRaw) == 0 || len(sizeRaw) > 15 { // 15 hex digits fits int64
+		return 0, ErrMalformedChunk
 	}
 
-	if cl > int64(maxBodyBytes) {
-		return false, 0, ErrMessageTooLarge
+	var size int64
+	for _, c := range sizeRaw {
+		var d byte
+		switch {
+		case c >= '0' && c <= '9':
+			d = c - '0'
+		case c >= 'a' && c <= 'f':
+			d = c - 'a' + 10
+		case c >= 'A' && c <= 'F':
+			d = c - 'A' + 10
+		default:
+			return 0, ErrMalformedChunk // rejects signs, "0x", whitespace...
+		}
+		size = size<<4 | int64(d)
 	}
-	return true, int(cl), nil
+	return size, nil
 }
 
 // parse consumes data and attempts to parse the request line.
@@ -157,7 +427,7 @@ outer:
 			break outer
 
 		case RequestInitialized:
-			rl, n, err := ParseRequestLine(currentData)
+			rl, n, err := parseRequestLine(currentData, r.limits.MaxStartLine)
 			if err != nil {
 				return 0, r.setErr(err)
 			}
@@ -167,11 +437,12 @@ outer:
 			}
 
 			r.RequestLine = rl
+			r.startLineLen = n - len(separator)
 			read += n
 			r.state = RequestParsingHeaders // transition
 
 		case RequestParsingHeaders:
-			n, endOfHeaders, err := r.Headers.Parse(currentData)
+			n, endOfHeaders, err := r.parseHeaders(currentData)
 			if err != nil {
 				return 0, r.setErr(err)
 			}
@@ -183,53 +454,43 @@ outer:
 			read += n
 
 			if endOfHeaders {
-				has, _, err := r.hasBody()
+				if r.limitsFor != nil {
+					// Limits for this route: zero fields keep the current
+					// ones, and the header section must fit them too.
+					r.limits = r.limitsFor(r).or(r.limits)
+					if err := r.checkLimits(r.limits, 0); err != nil {
+						return 0, r.setErr(err)
+					}
+				}
+
+				framing, want, err := r.bodyFraming()
 				if err != nil {
 					return 0, r.setErr(err)
 				}
 
-				if !has {
+				if framing == bodyNone {
 					r.state = RequestDone
 					break outer
 				}
 
-				// There is a positive-length body; start consuming now.
+				// There is a body; stash its framing and start consuming now.
+				r.framing = framing
+				r.bodyWant = want
 				r.state = RequestParsingBody
-				// Optionally stash want somewhere if you don't want to call hasBody() again.
 				continue
 			}
 
 		case RequestParsingBody:
-			has, want, err := r.hasBody()
-			if err != nil {
-				return 0, r.setErr(err)
-			}
-
-			if !has {
-				// Defensive: headers said no body (or CL=0)
-				r.state = RequestDone
-				break outer
+			if r.streaming {
+				break outer // body is pulled later through BodyReader
 			}
 
-			have := len(r.Body)
-			if have > want {
-				return 0, r.setErr(ErrRequestBodyExceedsCL)
-			}
-
-			if have == want {
-				r.state = RequestDone
-				break outer
-			}
-
-			// Consume up to remaining bytes from currentData
-			remaining := want - have
-			toRead := min(remaining, len(currentData))
-			if toRead > 0 {
-				r.Body = append(r.Body, currentData[:toRead]...)
-				read += toRead
+			n, done, err := r.parseBody(currentData, math.MaxInt, r.appendBody)
+			if err != nil {
+				return 0, r.setErr(err)
 			}
-
-			if len(r.Body) == want {
+			read += n
+			if done {
 				r.state = RequestDone
 			}
 			break outer
@@ -245,47 +506,128 @@ outer:
 	return read, nil
 }
 
-// RequestFromReader reads from r until the start-line is parsed,
-// or an error occurs. It enforces maxStartLine size.
-// Any extra bytes read (e.g., beginning of headers) remain in the
-// caller's buffer in this implementation; we stop at ParsingHeaders.
+// RequestFromReader reads a complete request from r, buffering the body
+// into Request.Body, within DefaultLimits; set Reader.Limits for others.
+// Any extra bytes read past the end of the request are discarded; use a
+// Reader to keep them for the next request on the same connection.
+// Returns io.EOF if r ends before the first byte of a request.
 func RequestFromReader(r io.Reader) (*Request, error) {
-	req := newRequest()
+	return NewReader(r).ReadRequest()
+}
+
+// RequestFromReaderLimits is like RequestFromReader but within l; zero
+// fields use DefaultLimits.
+func RequestFromReaderLimits(r io.Reader, l Limits) (*Request, error) {
+	reader := NewReader(r)
+	reader.Limits = l
+	return reader.ReadRequest()
+}
+
+// StreamRequestFromReader reads from r only up to the end of the header
+// section and returns. The body is left on the wire and decoded lazily
+// (Content-Length or chunked) through Request.BodyReader, so it is not
+// capped at MaxBodyBytes; Request.Body stays nil.
+// The caller must not read from r again until BodyReader hits EOF.
+func StreamRequestFromReader(r io.Reader) (*Request, error) {
+	return NewReader(r).StreamRequest()
+}
+
+// Reader parses successive requests from one connection. Bytes read past
+// the end of one request (e.g. a pipelined second request) are kept and
+// fed into the next parse.
+type Reader struct {
+	// OnHeaders, if set, is called once the header section of each request
+	// has been parsed and before any further reads for its body
+	// (e.g. to swap a header read deadline for a body one).
+	OnHeaders func(*Request)
+
+	// KeepRawHeaders fills Request.RawHeaders alongside Request.Headers.
+	KeepRawHeaders bool
+
+	// Limits caps each request; zero fields use DefaultLimits.
+	Limits Limits
+
+	// LimitsFor, if set, is called once the header section of each request
+	// has been parsed and returns the limits for the rest of it (e.g. those
+	// of the route it is for). Zero fields keep Limits. Start-line and
+	// header limits can only tighten what Limits already let through.
+	LimitsFor func(*Request) Limits
+
+	src  io.Reader
+	buf  []byte      // read from src but not yet parsed
+	tmp  []byte      // scratch buffer for each read from src
+	body *bodyReader // body of the last streamed request
+}
 
-	// buf accumulates bytes we haven't yet parsed.
-	buf := make([]byte, 0, 256)
-	// tmp is a scratch buffer for each read from r.
-	tmp := make([]byte, 1024)
+func NewReader(r io.Reader) *Reader {
+	return &Reader{
+		src: r,
+		buf: make([]byte, 0, 256),
+		tmp: make([]byte, 32*1024),
+	}
+}
 
-	for !req.done() {
-		n, err := r.Read(tmp)
+// ReadRequest reads the next request, buffering its body into Request.Body.
+func (cr *Reader) ReadRequest() (*Request, error) {
+	req, err := cr.readRequest(false)
+	if err != nil {
+		return nil, err
+	}
+	req.BodyReader = io.NopCloser(bytes.NewReader(req.Body))
+	return req, nil
+}
+
+// StreamRequest reads the next request up to the end of its header section;
+// the body is pulled from the connection through Request.BodyReader.
+// It must be read to EOF before the next request can be read.
+func (cr *Reader) StreamRequest() (*Request, error) {
+	req, err := cr.readRequest(true)
+	if err != nil {
+		return nil, err
+	}
+	cr.body = &bodyReader{req: req, conn: cr}
+	req.BodyReader = cr.body
+	return req, nil
+}
+
+// readRequest runs the parse loop until the request is ready (see
+// Request.ready), leaving any bytes past it in cr.buf.
+func (cr *Reader) readRequest(stream bool) (*Request, error) {
+	if cr.body != nil && !cr.body.req.done() {
+		return nil, ErrBodyNotConsumed
+	}
+	cr.body = nil
+
+	req := newRequest()
+	req.streaming = stream
+	req.keepRaw = cr.KeepRawHeaders
+	req.limits = cr.Limits.or(DefaultLimits)
+	req.limitsFor = cr.LimitsFor
+
+	// Leftovers from the previous request may already hold this one.
+	if len(cr.buf) > 0 {
+		if err := cr.parse(req); err != nil {
+			return nil, err
+		}
+	}
+
+	for !req.ready() {
+		n, err := cr.src.Read(cr.tmp)
 
 		if n > 0 {
 			// Append new data into our buffer
-			buf = append(buf, tmp[:n]...)
-
-			// Enforce start-line cap ONLY before the start-line is parsed.
-			if req.state == RequestInitialized && len(buf) > maxStartLine {
-				return nil, ErrMalformedRequestLine
-			}
+			cr.buf = append(cr.buf, cr.tmp[:n]...)
 
 			// Try to parse what we have so far
-			readN, perr := req.parse(buf)
-			if perr != nil {
+			if perr := cr.parse(req); perr != nil {
 				return nil, perr
 			}
-
-			if readN > 0 {
-				// Shift leftover (unparsed) data down to front of buffer
-				copy(buf, buf[readN:])
-				buf = buf[:len(buf)-readN]
-			}
 		}
 
 		if err != nil {
 			if err == io.EOF {
 				// give parser a last chance if you want; then:
-				if req.done() {
+				if req.ready() {
 					break
 				}
 
@@ -294,6 +636,12 @@ func RequestFromReader(r io.Reader) (*Request, error) {
 					return nil, req.parseErr
 				}
 
+				// Peer closed before sending anything: a clean end of a
+				// keep-alive connection rather than a truncated request.
+				if req.state == RequestInitialized && len(cr.buf) == 0 {
+					return nil, io.EOF
+				}
+
 				return nil, io.ErrUnexpectedEOF
 			}
 
@@ -308,16 +656,66 @@ func RequestFromReader(r io.Reader) (*Request, error) {
 	return req, nil
 }
 
+// WaitForRequest blocks until at least one byte of the next request is
+// buffered. It returns io.EOF if the peer closes the connection first.
+func (cr *Reader) WaitForRequest() error {
+	for len(cr.buf) == 0 {
+		n, err := cr.src.Read(cr.tmp)
+		cr.buf = append(cr.buf, cr.tmp[:n]...)
+		if n > 0 {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parse feeds cr.buf to req and drops the bytes it consumed.
+func (cr *Reader) parse(req *Request) error {
+	before := req.state
+	readN, err := req.parse(cr.buf)
+	if err != nil {
+		return err
+	}
+
+	if cr.OnHeaders != nil && before < RequestParsingBody &&
+		(req.state == RequestParsingBody || req.state == RequestDone) {
+		cr.OnHeaders(req)
+	}
+
+	if readN > 0 {
+		// Shift leftover (unparsed) data down to front of buffer
+		copy(cr.buf, cr.buf[readN:])
+		cr.buf = cr.buf[:len(cr.buf)-readN]
+	}
+
+	// Enforce start-line cap ONLY before the start-line is parsed.
+	if req.state == RequestInitialized && len(cr.buf) > req.limits.MaxStartLine {
+		return req.setErr(ErrRequestLineTooLong)
+	}
+	return nil
+}
+
 // ParseRequestLine attempts to parse a single HTTP request line from s.
 // Returns (*RequestLine, bytesConsumedIncludingCRLF, error).
 // If no CRLF yet, returns (nil, 0, nil).
 func ParseRequestLine(s []byte) (*RequestLine, int, error) {
+	return parseRequestLine(s, DefaultLimits.MaxStartLine)
+}
+
+// parseRequestLine is ParseRequestLine with a cap of maxLen bytes.
+func parseRequestLine(s []byte, maxLen int) (*RequestLine, int, error) {
 	// Find CRLF terminator
 	idx := bytes.Index(s, separator)
 	if idx == -1 {
 		// Not enough data yet
 		return nil, 0, nil
 	}
+	if idx > maxLen {
+		return nil, 0, ErrRequestLineTooLong
+	}
 
 	startLine := s[:idx]
 
@@ -333,11 +731,21 @@ func ParseRequestLine(s []byte) (*RequestLine, int, error) {
 	if _, ok := allowedMethods[string(m)]; !ok {
 		return nil, 0, ErrUnsupportedHTTPMethod
 	}
-	// Validate version (only HTTP/1.1 supported here)
-	if !bytes.Equal(ver, []byte("HTTP/1.1")) {
+	// Validate version: "HTTP/" DIGIT "." DIGIT, and only major version 1
+	// (1.0, 1.1, and later 1.x minors treated as 1.1).
+	if len(ver) != 8 || !bytes.HasPrefix(ver, []byte("HTTP/")) ||
+		!isDigit(ver[5]) || ver[6] != '.' || !isDigit(ver[7]) {
+		return nil, 0, ErrMalformedRequestLine
+	}
+	if ver[5] != '1' {
 		return nil, 0, ErrUnsupportedHTTPVersion
 	}
 
+	t, err := ParseTarget(string(m), string(target))
+	if err != nil {
+		return nil, 0, err
+	}
+
 	// Number of bytes consumed includes CRLF
 	parsedBytes := idx + len(separator)
 
@@ -345,6 +753,9 @@ func ParseRequestLine(s []byte) (*RequestLine, int, error) {
 	return &RequestLine{
 		Method:        string(m),
 		RequestTarget: string(target),
-		HTTPVersion:   string(bytes.Split(ver, []byte("/"))[1]), // "1.1"
+		HTTPVersion:   string(ver[5:]), // "1.1"
+		Target:        t,
 	}, parsedBytes, nil
 }
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
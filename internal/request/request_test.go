@@ -1,6 +1,7 @@
 package request
 
 import (
+	"httpfromtcp/internal/headers"
 	"io"
 	"strings"
 	"testing"
@@ -120,3 +121,300 @@ func TestRequestbody(t *testing.T) {
 	r, err = RequestFromReader(reader)
 	require.Error(t, err)
 }
+
+func TestRequestChunkedBody(t *testing.T) {
+	// Test: Chunked body with extensions and an empty trailer section
+	reader := &chunkReader{
+		data: "POST /upload HTTP/1.1\r\n" +
+			"Host: localhost:42069\r\n" +
+			"Transfer-Encoding: chunked\r\n" +
+			"\r\n" +
+			"6\r\nhello \r\n" +
+			"6;name=value\r\nworld!\r\n" +
+			"0\r\n" +
+			"\r\n",
+		numBytesPerRead: 3,
+	}
+	r, err := RequestFromReader(reader)
+	require.NoError(t, err)
+	require.NotNil(t, r)
+	assert.Equal(t, "hello world!", string(r.Body))
+
+	// Test: Uppercase hex sizes, one byte per read
+	reader = &chunkReader{
+		data: "POST /upload HTTP/1.1\r\n" +
+			"Transfer-Encoding: chunked\r\n" +
+			"\r\n" +
+			"A\r\n0123456789\r\n" +
+			"0\r\n" +
+			"\r\n",
+		numBytesPerRead: 1,
+	}
+	r, err = RequestFromReader(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", string(r.Body))
+
+	// Test: Invalid chunk size
+	_, err = RequestFromReader(strings.NewReader("POST / HTTP/1.1\r\n" +
+		"Transfer-Encoding: chunked\r\n\r\n" +
+		"zz\r\nhello\r\n0\r\n\r\n"))
+	require.ErrorIs(t, err, ErrMalformedChunk)
+
+	// Test: Signed chunk size is not hex
+	_, err = RequestFromReader(strings.NewReader("POST / HTTP/1.1\r\n" +
+		"Transfer-Encoding: chunked\r\n\r\n" +
+		"+5\r\nhello\r\n0\r\n\r\n"))
+	require.ErrorIs(t, err, ErrMalformedChunk)
+
+	// Test: Chunk data longer than its size
+	_, err = RequestFromReader(strings.NewReader("POST / HTTP/1.1\r\n" +
+		"Transfer-Encoding: chunked\r\n\r\n" +
+		"3\r\nhello\r\n0\r\n\r\n"))
+	require.ErrorIs(t, err, ErrMalformedChunk)
+
+	// Test: Missing terminating chunk
+	_, err = RequestFromReader(strings.NewReader("POST / HTTP/1.1\r\n" +
+		"Transfer-Encoding: chunked\r\n\r\n" +
+		"5\r\nhello\r\n"))
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	// Test: Chunk size over the body cap
+	_, err = RequestFromReader(strings.NewReader("POST / HTTP/1.1\r\n" +
+		"Transfer-Encoding: chunked\r\n\r\n" +
+		"FFFFFFFF\r\n"))
+	require.ErrorIs(t, err, ErrMessageTooLarge)
+
+	// Test: Transfer-Encoding together with Content-Length
+	_, err = RequestFromReader(strings.NewReader("POST / HTTP/1.1\r\n" +
+		"Transfer-Encoding: chunked\r\nContent-Length: 5\r\n\r\n" +
+		"5\r\nhello\r\n0\r\n\r\n"))
+	require.ErrorIs(t, err, ErrConflictingFraming)
+
+	// Test: Unsupported transfer coding
+	_, err = RequestFromReader(strings.NewReader("POST / HTTP/1.1\r\n" +
+		"Transfer-Encoding: gzip, chunked\r\n\r\n"))
+	require.ErrorIs(t, err, ErrUnsupportedTE)
+}
+
+func TestRequestChunkedTrailers(t *testing.T) {
+	// Test: Trailer fields after the last chunk
+	reader := &chunkReader{
+		data: "POST /upload HTTP/1.1\r\n" +
+			"Host: localhost:42069\r\n" +
+			"Transfer-Encoding: chunked\r\n" +
+			"Trailer: Digest, X-Count\r\n" +
+			"\r\n" +
+			"5\r\nhello\r\n" +
+			"0\r\n" +
+			"Digest: sha-256=LPJNul+wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ=\r\n" +
+			"X-Count: 1\r\n" +
+			"\r\n",
+		numBytesPerRead: 4,
+	}
+	r, err := RequestFromReader(reader)
+	require.NoError(t, err)
+	require.NotNil(t, r)
+	assert.Equal(t, "hello", string(r.Body))
+	assert.Equal(t, "sha-256=LPJNul+wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ=", r.Trailers.Get("Digest"))
+	assert.Equal(t, "1", r.Trailers.Get("x-count"))
+	assert.Equal(t, "", r.Headers.Get("Digest"))
+
+	// Test: Malformed trailer field
+	_, err = RequestFromReader(strings.NewReader("POST / HTTP/1.1\r\n" +
+		"Transfer-Encoding: chunked\r\n\r\n" +
+		"0\r\nDigest sha-256=abc\r\n\r\n"))
+	require.Error(t, err)
+
+	// Test: Unterminated trailer section
+	_, err = RequestFromReader(strings.NewReader("POST / HTTP/1.1\r\n" +
+		"Transfer-Encoding: chunked\r\n\r\n" +
+		"0\r\nDigest: sha-256=abc\r\n"))
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestStreamRequestBody(t *testing.T) {
+	// Test: Content-Length body is left for BodyReader
+	reader := &chunkReader{
+		data: "POST /upload HTTP/1.1\r\n" +
+			"Host: localhost:42069\r\n" +
+			"Content-Length: 13\r\n" +
+			"\r\n" +
+			"hello world!\n",
+		numBytesPerRead: 5,
+	}
+	r, err := StreamRequestFromReader(reader)
+	require.NoError(t, err)
+	require.NotNil(t, r)
+	assert.Nil(t, r.Body)
+	assert.Equal(t, RequestParsingBody, r.state)
+	body, err := io.ReadAll(r.BodyReader)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world!\n", string(body))
+	require.NoError(t, r.BodyReader.Close())
+
+	// Test: Chunked body and trailers, read through a tiny buffer
+	reader = &chunkReader{
+		data: "POST /upload HTTP/1.1\r\n" +
+			"Transfer-Encoding: chunked\r\n" +
+			"\r\n" +
+			"6\r\nhello \r\n" +
+			"6\r\nworld!\r\n" +
+			"0\r\n" +
+			"X-Count: 2\r\n" +
+			"\r\n",
+		numBytesPerRead: 7,
+	}
+	r, err = StreamRequestFromReader(reader)
+	require.NoError(t, err)
+	var got []byte
+	p := make([]byte, 4)
+	for {
+		n, err := r.BodyReader.Read(p)
+		got = append(got, p[:n]...)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+	}
+	assert.Equal(t, "hello world!", string(got))
+	assert.Equal(t, "2", r.Trailers.Get("X-Count"))
+
+	// Test: Content-Length above the buffering cap is fine when streaming
+	r, err = StreamRequestFromReader(strings.NewReader("POST / HTTP/1.1\r\n" +
+		"Content-Length: 20971520\r\n\r\n"))
+	require.NoError(t, err)
+	_, err = io.ReadAll(r.BodyReader)
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	// Test: No body
+	r, err = StreamRequestFromReader(strings.NewReader("GET / HTTP/1.1\r\nHost: x\r\n\r\n"))
+	require.NoError(t, err)
+	body, err = io.ReadAll(r.BodyReader)
+	require.NoError(t, err)
+	assert.Empty(t, body)
+
+	// Test: Read after Close
+	r, err = StreamRequestFromReader(strings.NewReader("POST / HTTP/1.1\r\nContent-Length: 2\r\n\r\nhi"))
+	require.NoError(t, err)
+	require.NoError(t, r.BodyReader.Close())
+	_, err = r.BodyReader.Read(p)
+	require.ErrorIs(t, err, ErrBodyReadAfterClose)
+}
+
+func TestRequestCleanEOF(t *testing.T) {
+	// Test: Nothing sent at all is a clean close, not a truncated request
+	_, err := RequestFromReader(strings.NewReader(""))
+	require.ErrorIs(t, err, io.EOF)
+
+	// Test: Partial start-line is still truncated
+	_, err = RequestFromReader(strings.NewReader("GET / HT"))
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestReaderPipelining(t *testing.T) {
+	// Test: Three pipelined requests delivered in odd-sized reads
+	reader := &chunkReader{
+		data: "GET /one HTTP/1.1\r\nHost: localhost:42069\r\n\r\n" +
+			"POST /two HTTP/1.1\r\nContent-Length: 5\r\n\r\nhello" +
+			"POST /three HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n3\r\nabc\r\n0\r\n\r\n",
+		numBytesPerRead: 37,
+	}
+	cr := NewReader(reader)
+
+	r, err := cr.ReadRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "/one", r.RequestLine.RequestTarget)
+
+	r, err = cr.ReadRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "/two", r.RequestLine.RequestTarget)
+	assert.Equal(t, "hello", string(r.Body))
+
+	r, err = cr.ReadRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "/three", r.RequestLine.RequestTarget)
+	assert.Equal(t, "abc", string(r.Body))
+
+	_, err = cr.ReadRequest()
+	require.ErrorIs(t, err, io.EOF)
+
+	// Test: Whole pipeline arrives in one read
+	cr = NewReader(strings.NewReader("GET /a HTTP/1.1\r\n\r\nGET /b HTTP/1.1\r\n\r\n"))
+	r, err = cr.ReadRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "/a", r.RequestLine.RequestTarget)
+	r, err = cr.ReadRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "/b", r.RequestLine.RequestTarget)
+
+	// Test: Streamed bodies hand leftovers back to the connection
+	cr = NewReader(strings.NewReader("POST /a HTTP/1.1\r\nContent-Length: 3\r\n\r\nabc" +
+		"POST /b HTTP/1.1\r\nContent-Length: 3\r\n\r\ndef" +
+		"GET /c HTTP/1.1\r\n\r\n"))
+	r, err = cr.StreamRequest()
+	require.NoError(t, err)
+	_, err = cr.StreamRequest()
+	require.ErrorIs(t, err, ErrBodyNotConsumed)
+	body, err := io.ReadAll(r.BodyReader)
+	require.NoError(t, err)
+	assert.Equal(t, "abc", string(body))
+
+	r, err = cr.StreamRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "/b", r.RequestLine.RequestTarget)
+	require.NoError(t, r.BodyReader.Close())
+	require.True(t, cr.DiscardBody(1024))
+
+	r, err = cr.StreamRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "/c", r.RequestLine.RequestTarget)
+
+	// Test: Unread body over the discard limit
+	cr = NewReader(strings.NewReader("POST /a HTTP/1.1\r\nContent-Length: 10\r\n\r\n0123456789"))
+	_, err = cr.StreamRequest()
+	require.NoError(t, err)
+	assert.False(t, cr.DiscardBody(4))
+}
+
+func TestReaderKeepRawHeaders(t *testing.T) {
+	// Test: Raw lines keep order and casing, across small reads
+	reader := &chunkReader{
+		data: "GET / HTTP/1.1\r\nHost: localhost:42069\r\nX-Trace-ID: abc\r\n" +
+			"accept: */*\r\nx-trace-id: def\r\n\r\n",
+		numBytesPerRead: 5,
+	}
+	cr := NewReader(reader)
+	cr.KeepRawHeaders = true
+	r, err := cr.ReadRequest()
+	require.NoError(t, err)
+	assert.Equal(t, headers.RawHeaders{
+		{Name: "Host", Value: "localhost:42069"},
+		{Name: "X-Trace-ID", Value: "abc"},
+		{Name: "accept", Value: "*/*"},
+		{Name: "x-trace-id", Value: "def"},
+	}, r.RawHeaders)
+	assert.Equal(t, []string{"abc", "def"}, r.Headers.Values("x-trace-id"))
+
+	// Test: Off by default
+	r, err = RequestFromReader(strings.NewReader("GET / HTTP/1.1\r\nHost: x\r\n\r\n"))
+	require.NoError(t, err)
+	assert.Nil(t, r.RawHeaders)
+	assert.Equal(t, "x", r.Headers.Get("host"))
+}
+
+func TestRequestHTTPVersions(t *testing.T) {
+	// Test: HTTP/1.0 is accepted
+	r, err := RequestFromReader(strings.NewReader("GET / HTTP/1.0\r\n\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "1.0", r.RequestLine.HTTPVersion)
+
+	// Test: Unknown major versions are unsupported, bad syntax is malformed
+	_, err = RequestFromReader(strings.NewReader("GET / HTTP/2.0\r\n\r\n"))
+	require.ErrorIs(t, err, ErrUnsupportedHTTPVersion)
+	_, err = RequestFromReader(strings.NewReader("GET / HTTP/0.9\r\n\r\n"))
+	require.ErrorIs(t, err, ErrUnsupportedHTTPVersion)
+	for _, v := range []string{"HTTP/1", "HTTP/1.10", "http/1.1", "HTTP/1,1", "HTTP/x.1"} {
+		_, err = RequestFromReader(strings.NewReader("GET / " + v + "\r\n\r\n"))
+		require.ErrorIs(t, err, ErrMalformedRequestLine, v)
+	}
+}
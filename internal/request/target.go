@@ -0,0 +1,225 @@
+package request
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrInvalidRequestTarget = errors.New("invalid request-target")
+
+// TargetForm is the shape of a request-target (RFC 9112 3.2).
+type TargetForm int
+
+const (
+	OriginForm    TargetForm = iota + 1 // "/path?query"
+	AbsoluteForm                        // "http://host/path?query", sent to proxies
+	AuthorityForm                       // "host:port", CONNECT only
+	AsteriskForm                        // "*", server-wide OPTIONS only
+)
+
+var TargetFormName = map[TargetForm]string{
+	OriginForm:    "origin-form",
+	AbsoluteForm:  "absolute-form",
+	AuthorityForm: "authority-form",
+	AsteriskForm:  "asterisk-form",
+}
+
+// Target is a parsed request-target.
+type Target struct {
+	Form TargetForm
+
+	Scheme string // absolute-form only, lowercase
+	Host   string // "host[:port]" for absolute- and authority-form
+
+	// Path is percent-decoded with "." and ".." segments removed, and
+	// always starts with "/" for origin- and absolute-form. An encoded
+	// slash stays "%2F" so it cannot split or merge segments. It is "" for
+	// authority- and asterisk-form.
+	Path    string
+	RawPath string // path as sent
+
+	RawQuery string // without the "?"
+	Query    Query
+}
+
+// Query holds decoded query parameters; a name may repeat.
+type Query map[string][]string
+
+// Get returns the first value for name, or "".
+func (q Query) Get(name string) string {
+	if vs := q[name]; len(vs) > 0 {
+		return vs[0]
+	}
+	return ""
+}
+
+// Values returns every value for name in the order they were sent.
+func (q Query) Values(name string) []string {
+	return q[name]
+}
+
+func (q Query) Has(name string) bool {
+	_, ok := q[name]
+	return ok
+}
+
+// ParseTarget parses the request-target of a method request. Which forms
+// are allowed depends on the method: authority-form is only for CONNECT,
+// asterisk-form only for OPTIONS. Errors wrap ErrInvalidRequestTarget.
+func ParseTarget(method, target string) (*Target, error) {
+	for i := 0; i < len(target); i++ {
+		// No whitespace, controls or raw non-ASCII; a fragment is never sent.
+		if c := target[i]; c <= ' ' || c >= 0x7f || c == '#' {
+			return nil, fmt.Errorf("%w: bad byte %q", ErrInvalidRequestTarget, c)
+		}
+	}
+
+	switch {
+	case method == "CONNECT":
+		return parseAuthorityForm(target)
+	case target == "*":
+		if method != "OPTIONS" {
+			return nil, fmt.Errorf("%w: %q is only for OPTIONS", ErrInvalidRequestTarget, target)
+		}
+		return &Target{Form: AsteriskForm}, nil
+	case strings.HasPrefix(target, "/"):
+		t := &Target{Form: OriginForm}
+		return t, t.parsePathQuery(target)
+	}
+
+	scheme, rest, ok := strings.Cut(target, "://")
+	scheme = strings.ToLower(scheme)
+	if !ok || (scheme != "http" && scheme != "https") {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidRequestTarget, target)
+	}
+
+	end := strings.IndexAny(rest, "/?")
+	if end == -1 {
+		end = len(rest)
+	}
+	t := &Target{Form: AbsoluteForm, Scheme: scheme, Host: rest[:end]}
+	if t.Host == "" || strings.Contains(t.Host, "@") {
+		return nil, fmt.Errorf("%w: bad host in %q", ErrInvalidRequestTarget, target)
+	}
+
+	pathQuery := rest[end:]
+	if !strings.HasPrefix(pathQuery, "/") {
+		pathQuery = "/" + pathQuery // "http://host" or "http://host?q" mean "/"
+	}
+	return t, t.parsePathQuery(pathQuery)
+}
+
+// parseAuthorityForm accepts "host:port", the only form CONNECT allows.
+func parseAuthorityForm(target string) (*Target, error) {
+	host, port, ok := strings.Cut(target, ":")
+	if strings.HasPrefix(target, "[") { // IPv6 literal
+		i := strings.Index(target, "]")
+		if i == -1 {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidRequestTarget, target)
+		}
+		host, port, ok = target[:i+1], strings.TrimPrefix(target[i+1:], ":"), strings.HasPrefix(target[i+1:], ":")
+	}
+	if !ok || host == "" || port == "" || strings.ContainsAny(target, "/?@") ||
+		strings.Trim(port, "0123456789") != "" {
+		return nil, fmt.Errorf("%w: CONNECT needs host:port, got %q", ErrInvalidRequestTarget, target)
+	}
+	return &Target{Form: AuthorityForm, Host: target}, nil
+}
+
+// parsePathQuery fills in the path and query from "/path[?query]".
+func (t *Target) parsePathQuery(s string) error {
+	t.RawPath, t.RawQuery, _ = strings.Cut(s, "?")
+
+	path, err := unescape(t.RawPath, false)
+	if err != nil {
+		return err
+	}
+	t.Path = removeDotSegments(path)
+
+	t.Query = Query{}
+	for pair := range strings.SplitSeq(t.RawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		k, v, _ := strings.Cut(pair, "=")
+		if k, err = unescape(k, true); err != nil {
+			return err
+		}
+		if v, err = unescape(v, true); err != nil {
+			return err
+		}
+		t.Query[k] = append(t.Query[k], v)
+	}
+	return nil
+}
+
+// unescape decodes %XX escapes. In a query "+" is a space too; in a path
+// "%2F" is kept (uppercased) since decoding it would change the segments.
+func unescape(s string, query bool) (string, error) {
+	if !strings.ContainsAny(s, "%+") {
+		return s, nil
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '%':
+			if i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) {
+				return "", fmt.Errorf("%w: bad escape in %q", ErrInvalidRequestTarget, s)
+			}
+			c = unhex(s[i+1])<<4 | unhex(s[i+2])
+			if c == '/' && !query {
+				b.WriteString("%2F")
+			} else {
+				b.WriteByte(c)
+			}
+			i += 2
+		case c == '+' && query:
+			b.WriteByte(' ')
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), nil
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case c <= '9':
+		return c - '0'
+	case c <= 'F':
+		return c - 'A' + 10
+	default:
+		return c - 'a' + 10
+	}
+}
+
+// removeDotSegments resolves "." and ".." segments in an absolute path
+// (RFC 3986 5.2.4), never climbing above the root.
+func removeDotSegments(path string) string {
+	segs := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	out := make([]string, 0, len(segs))
+	for i, seg := range segs {
+		last := i == len(segs)-1
+		switch seg {
+		case ".":
+		case "..":
+			if len(out) > 0 {
+				out = out[:len(out)-1]
+			}
+		default:
+			out = append(out, seg)
+			continue
+		}
+		if last {
+			out = append(out, "") // "/a/." and "/a/b/.." keep the trailing slash
+		}
+	}
+	return "/" + strings.Join(out, "/")
+}
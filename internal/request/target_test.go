@@ -0,0 +1,101 @@
+package request
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTarget(t *testing.T) {
+	// Test: Origin-form with decoding, dot segments and a query
+	tg, err := ParseTarget("GET", "/a/./b/../c%20d/?x=1&y=a+b&x=2&flag&e=%3D")
+	require.NoError(t, err)
+	assert.Equal(t, OriginForm, tg.Form)
+	assert.Equal(t, "/a/c d/", tg.Path)
+	assert.Equal(t, "/a/./b/../c%20d/", tg.RawPath)
+	assert.Equal(t, "x=1&y=a+b&x=2&flag&e=%3D", tg.RawQuery)
+	assert.Equal(t, "1", tg.Query.Get("x"))
+	assert.Equal(t, []string{"1", "2"}, tg.Query.Values("x"))
+	assert.Equal(t, "a b", tg.Query.Get("y"))
+	assert.Equal(t, "=", tg.Query.Get("e"))
+	assert.True(t, tg.Query.Has("flag"))
+	assert.False(t, tg.Query.Has("missing"))
+
+	// Test: Dot segments never climb above the root
+	tg, err = ParseTarget("GET", "/../../etc/passwd")
+	require.NoError(t, err)
+	assert.Equal(t, "/etc/passwd", tg.Path)
+	tg, err = ParseTarget("GET", "/a/%2e%2e")
+	require.NoError(t, err)
+	assert.Equal(t, "/", tg.Path)
+
+	// Test: An encoded slash stays encoded and within its segment
+	tg, err = ParseTarget("GET", "/users/a%2fb/%2E%2E%2F..%2F/x")
+	require.NoError(t, err)
+	assert.Equal(t, "/users/a%2Fb/..%2F..%2F/x", tg.Path)
+	tg, err = ParseTarget("GET", "/files?p=a%2Fb")
+	require.NoError(t, err)
+	assert.Equal(t, "/files", tg.Path)
+	assert.Equal(t, "a/b", tg.Query.Get("p"))
+
+	// Test: Absolute-form
+	tg, err = ParseTarget("GET", "HTTP://example.com:8080/x?q=1")
+	require.NoError(t, err)
+	assert.Equal(t, AbsoluteForm, tg.Form)
+	assert.Equal(t, "http", tg.Scheme)
+	assert.Equal(t, "example.com:8080", tg.Host)
+	assert.Equal(t, "/x", tg.Path)
+	assert.Equal(t, "1", tg.Query.Get("q"))
+	tg, err = ParseTarget("GET", "https://example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "/", tg.Path)
+
+	// Test: Authority-form, CONNECT only
+	tg, err = ParseTarget("CONNECT", "example.com:443")
+	require.NoError(t, err)
+	assert.Equal(t, AuthorityForm, tg.Form)
+	assert.Equal(t, "example.com:443", tg.Host)
+	assert.Empty(t, tg.Path)
+	tg, err = ParseTarget("CONNECT", "[::1]:443")
+	require.NoError(t, err)
+	assert.Equal(t, "[::1]:443", tg.Host)
+
+	// Test: Asterisk-form, OPTIONS only
+	tg, err = ParseTarget("OPTIONS", "*")
+	require.NoError(t, err)
+	assert.Equal(t, AsteriskForm, tg.Form)
+
+	// Test: Invalid targets
+	for _, c := range []struct{ method, target string }{
+		{"GET", "*"},
+		{"GET", "example.com:443"},
+		{"GET", "relative/path"},
+		{"GET", "ftp://example.com/"},
+		{"GET", "http:///nohost"},
+		{"GET", "http://user@example.com/"},
+		{"GET", "/frag#ment"},
+		{"GET", "/bad%zzescape"},
+		{"GET", "/trunc%2"},
+		{"GET", "/q?a=%"},
+		{"GET", "/ctl\x01"},
+		{"CONNECT", "/path"},
+		{"CONNECT", "example.com"},
+		{"CONNECT", "example.com:https"},
+	} {
+		_, err := ParseTarget(c.method, c.target)
+		assert.ErrorIs(t, err, ErrInvalidRequestTarget, "%s %q", c.method, c.target)
+	}
+}
+
+func TestRequestLineTarget(t *testing.T) {
+	r, err := RequestFromReader(strings.NewReader("GET /search?q=go HTTP/1.1\r\nHost: x\r\n\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "/search?q=go", r.RequestLine.RequestTarget)
+	assert.Equal(t, "/search", r.RequestLine.Target.Path)
+	assert.Equal(t, "go", r.RequestLine.Target.Query.Get("q"))
+
+	_, err = RequestFromReader(strings.NewReader("GET /a%zz HTTP/1.1\r\nHost: x\r\n\r\n"))
+	require.ErrorIs(t, err, ErrInvalidRequestTarget)
+}
@@ -1,65 +1,193 @@
 package response
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
 	"httpfromtcp/internal/headers"
 	"io"
 	"net/textproto"
-	"sort"
-	"strconv"
+	"slices"
 	"strings"
 )
 
-type StatusCode int
-
-const (
-	OK                    StatusCode = 200
-	BAD_REQUEST           StatusCode = 400
-	INTERNAL_SERVER_ERROR StatusCode = 500
-)
-
-var StatusCodeName = map[StatusCode]string{
-	OK:                    "OK",
-	BAD_REQUEST:           "Bad Request",
-	INTERNAL_SERVER_ERROR: "Internal Server Error",
-}
-
 const httpVersion = "HTTP/1.1"
 
-// GetDefaultHeaders returns a fresh headers map containing sensible defaults.
-// Keys are stored lowercase to match your headers.Headers behavior.
-func GetDefaultHeaders(contentLen int) headers.Headers {
-	h := headers.NewHeaders()
-	h.Set("content-length", strconv.Itoa(contentLen))
-	h.Set("connection", "close")
-	h.Set("content-type", "text/plain")
-	return h
-}
-
+// Writer builds a response. Handlers either fill in Status, Headers and Body
+// and let the server send them once they return (buffered), or stream the
+// response themselves through Write (or the Write* methods), in which case
+// it goes to the connection right away.
 type Writer struct {
-	writer       io.Writer
+	writer       *bufio.Writer
 	WriterStatus WriterStatus
 	Status       StatusCode
 	Headers      headers.Headers
 	Body         []byte
+
+	// Trailers are sent after the last chunk of a chunked body. Names must
+	// be announced with DeclareTrailer before the headers go out; values
+	// can be set any time before the body finishes.
+	Trailers headers.Headers
+
+	// ChunkSize is the amount of data collected from Writes before it goes
+	// out as one chunk of a chunked body. Zero means DefaultChunkSize.
+	ChunkSize int
+	// ChunkPerWrite sends every Write as exactly one chunk instead, however
+	// small or large (e.g. one event per chunk for SSE).
+	ChunkPerWrite bool
+
+	// SuppressBody sends the status line and headers as usual (framing
+	// included) but drops every body byte, as a HEAD response requires.
+	SuppressBody bool
+
+	// NoChunking is set for HTTP/1.0 clients, which cannot decode chunked
+	// bodies: a body of unknown length is then delimited by closing the
+	// connection (Connection: close, no framing header) and trailers are
+	// dropped.
+	NoChunking bool
+
+	// Defaults are fields sent unless the handler sets them itself
+	// (e.g. Date, Server, Content-Type). NewWriter starts with
+	// Content-Type: text/plain; the server replaces them per its Config.
+	Defaults headers.Headers
+
+	chunked bool   // headers announced Transfer-Encoding: chunked
+	closing bool   // body is close-delimited (NoChunking, no Content-Length)
+	pending []byte // chunk data waiting for ChunkSize or Flush
 }
 
+// DefaultChunkSize is the chunk data size used when Writer.ChunkSize is 0.
+const DefaultChunkSize = 8 * 1024 // 8 KiB
+
+// WriterStatus is the part of the response the Writer expects next.
+// The Write* methods only run in their own state and move it forward:
+//
+//	WritingStatusLine -> WritingHeaders -> WritingBody -> WritingDone
 type WriterStatus int
 
 const (
 	WritingStatusLine WriterStatus = iota + 1
 	WritingHeaders
 	WritingBody
+	WritingDone // body complete; nothing more may be written
 )
 
 var WriterStatusName = map[WriterStatus]string{
 	WritingStatusLine: "WRITING_STATUS_LINE",
 	WritingHeaders:    "WRITING_HEADERS",
 	WritingBody:       "WRITING_BODY",
+	WritingDone:       "WRITING_DONE",
+}
+
+var (
+	// ErrWriteOutOfOrder is wrapped by every WriterStateError.
+	ErrWriteOutOfOrder = errors.New("response written out of order")
+	// ErrBodyNotAllowed is returned when writing content for a status that
+	// cannot have any (1xx, 204, 304).
+	ErrBodyNotAllowed = errors.New("response status does not allow a body")
+	// ErrInvalidHeader is wrapped by every HeaderError.
+	ErrInvalidHeader = errors.New("invalid header field")
+)
+
+// WriterStateError reports a Write* call made in the wrong WriterStatus,
+// e.g. WriteBody before WriteHeaders. Nothing is written in that case.
+type WriterStateError struct {
+	Op   string       // method called, e.g. "WriteBody"
+	Want WriterStatus // state Op needs
+	Got  WriterStatus // state the Writer was in
+}
+
+func (e *WriterStateError) Error() string {
+	return fmt.Sprintf("%s: %v (writer is %s, needs %s)",
+		e.Op, ErrWriteOutOfOrder, WriterStatusName[e.Got], WriterStatusName[e.Want])
+}
+
+func (e *WriterStateError) Unwrap() error { return ErrWriteOutOfOrder }
+
+// HeaderError reports a field whose name is not a token or whose value is
+// not a valid field-value, e.g. one containing CR or LF that would split
+// the response. Nothing of the header section is written in that case.
+type HeaderError struct {
+	Name  string
+	Value string
+}
+
+func (e *HeaderError) Error() string {
+	if !headers.ValidName(e.Name) {
+		return fmt.Sprintf("%v: bad name %q", ErrInvalidHeader, e.Name)
+	}
+	return fmt.Sprintf("%v: bad value for %s: %q", ErrInvalidHeader, e.Name, e.Value)
+}
+
+func (e *HeaderError) Unwrap() error { return ErrInvalidHeader }
+
+// ValidateHeaders returns a HeaderError for the first field in h that
+// cannot be sent as-is, or nil.
+func ValidateHeaders(h headers.Headers) error {
+	for k, v := range h.All() {
+		if !headers.ValidName(k) || !headers.ValidValue(v) {
+			return &HeaderError{Name: k, Value: v}
+		}
+	}
+	return nil
 }
 
 func NewWriter(conn io.Writer) *Writer {
-	return &Writer{writer: conn}
+	return &Writer{
+		writer:       bufio.NewWriter(conn),
+		WriterStatus: WritingStatusLine,
+		Trailers:     headers.NewHeaders(),
+		Defaults:     headers.Headers{"content-type": {"text/plain"}},
+	}
+}
+
+// DeclareTrailer announces trailer fields in the Trailer header, which
+// also makes the body go out chunked. It must be called before the headers
+// are written.
+func (w *Writer) DeclareTrailer(names ...string) error {
+	if w.HeadersWritten() {
+		return &WriterStateError{Op: "DeclareTrailer", Want: WritingHeaders, Got: w.state()}
+	}
+	if w.Headers == nil {
+		w.Headers = headers.NewHeaders()
+	}
+	declared := append(w.Headers.Values("trailer"), names...)
+	w.Headers.Set("trailer", strings.Join(declared, ","))
+	return nil
+}
+
+// state returns WriterStatus, treating a zero Writer as fresh.
+func (w *Writer) state() WriterStatus {
+	if w.WriterStatus == 0 {
+		return WritingStatusLine
+	}
+	return w.WriterStatus
+}
+
+// expect returns a WriterStateError unless the Writer is in state want.
+func (w *Writer) expect(op string, want WriterStatus) error {
+	if got := w.state(); got != want {
+		return &WriterStateError{Op: op, Want: want, Got: got}
+	}
+	return nil
+}
+
+// StatusWritten reports whether the status line already went out, i.e.
+// whether it is too late to answer with a different status.
+func (w *Writer) StatusWritten() bool {
+	return w.state() > WritingStatusLine
+}
+
+// CloseDelimited reports whether the body went out without framing, so
+// the connection must be closed to end it (see NoChunking).
+func (w *Writer) CloseDelimited() bool {
+	return w.closing
+}
+
+// HeadersWritten reports whether the header section already went out, i.e.
+// whether it is too late to change headers (middleware can check this).
+func (w *Writer) HeadersWritten() bool {
+	return w.state() > WritingHeaders
 }
 
 func (w *Writer) SetBody(body []byte) {
@@ -67,51 +195,133 @@ func (w *Writer) SetBody(body []byte) {
 }
 
 func (w *Writer) WriteStatusLine(statusCode StatusCode) error {
-	reason, ok := StatusCodeName[statusCode]
-	if !ok {
-		reason = "Unknown"
+	if err := w.expect("WriteStatusLine", WritingStatusLine); err != nil {
+		return err
 	}
-	_, err := fmt.Fprintf(w.writer, "%s %d %s\r\n", httpVersion, int(statusCode), reason)
+
+	_, err := fmt.Fprintf(w.writer, "%s %d %s\r\n", httpVersion, int(statusCode), statusCode.Reason())
+	w.Status = statusCode
+	w.WriterStatus = WritingHeaders
 	return err
 }
 
 func (w *Writer) WriteHeaders(h headers.Headers) error {
+	if err := w.expect("WriteHeaders", WritingHeaders); err != nil {
+		return err
+	}
+
 	if h == nil {
+		w.WriterStatus = WritingBody
 		_, err := io.WriteString(w.writer, "\r\n")
 		return err
 	}
 
+	// Fill in writer-level defaults the caller and handler left unset
+	for k, v := range w.Defaults {
+		if len(h.Values(k)) == 0 && len(w.Headers.Values(k)) == 0 {
+			h[k] = slices.Clone(v)
+		}
+	}
+
 	// Overlay writer-level defaults/overrides if you have them
 	if w.Headers != nil {
-		for k := range w.Headers {
-			h.Override(k, w.Headers.Get(k))
+		for k, v := range w.Headers {
+			h[k] = slices.Clone(v)
 		}
 	}
 
-	// If Transfer-Encoding contains "chunked", do not send Content-Length
+	// 1xx/204/304 have no content, so no framing fields either
+	if !w.Status.AllowsBody() {
+		h.Del("content-length")
+		h.Del("transfer-encoding")
+	}
+
+	// Without chunking there are no trailers either, and a body of unknown
+	// length ends when the connection does.
 	te := strings.ToLower(h.Get("transfer-encoding"))
-	if tokenListContains(te, "chunked") {
-		h.Delete("content-length")
+	closing := false
+	if w.NoChunking {
+		h.Del("trailer")
+		if tokenListContains(te, "chunked") {
+			h.Del("transfer-encoding")
+			te = ""
+			if h.Get("content-length") == "" && w.Status.AllowsBody() {
+				h.Set("connection", "close")
+				closing = true
+			}
+		}
 	}
 
-	// Collect keys (your Headers store uses lowercase keys already)
-	keys := make([]string, 0, len(h))
-	for k := range h {
-		keys = append(keys, k)
+	// Trailers only exist in chunked bodies
+	if h.Get("trailer") != "" && w.Status.AllowsBody() && !tokenListContains(te, "chunked") {
+		h.Set("transfer-encoding", "chunked")
+		te = "chunked"
 	}
-	sort.Strings(keys)
 
-	for _, k := range keys {
-		display := textproto.CanonicalMIMEHeaderKey(k)
-		if _, err := fmt.Fprintf(w.writer, "%s: %s\r\n", display, h.Get(k)); err != nil {
+	// If Transfer-Encoding contains "chunked", do not send Content-Length
+	chunked := tokenListContains(te, "chunked")
+	if chunked {
+		h.Del("content-length")
+	}
+
+	// Refuse to emit anything a handler could use to split the response;
+	// the Writer stays in WritingHeaders so a corrected call can follow.
+	if err := ValidateHeaders(h); err != nil {
+		return err
+	}
+	w.WriterStatus = WritingBody
+	w.chunked = chunked
+	w.closing = closing
+
+	if err := w.writeFieldLines(h); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w.writer, "\r\n") // end of header block
+	return err
+}
+
+// WriteRawHeaders writes fields exactly as given, in order and with their
+// original name casing, for relaying a message received elsewhere. Unlike
+// WriteHeaders it adds nothing (no Defaults, no w.Headers) and leaves
+// framing to the fields themselves.
+func (w *Writer) WriteRawHeaders(fields headers.RawHeaders) error {
+	if err := w.expect("WriteRawHeaders", WritingHeaders); err != nil {
+		return err
+	}
+	for _, f := range fields {
+		if !headers.ValidName(f.Name) || !headers.ValidValue(f.Value) {
+			return &HeaderError{Name: f.Name, Value: f.Value}
+		}
+	}
+	w.WriterStatus = WritingBody
+
+	for _, f := range fields {
+		if strings.EqualFold(f.Name, "transfer-encoding") &&
+			tokenListContains(strings.ToLower(f.Value), "chunked") {
+			w.chunked = true
+		}
+		if _, err := fmt.Fprintf(w.writer, "%s: %s\r\n", f.Name, f.Value); err != nil {
 			return err
 		}
 	}
 
-	_, err := io.WriteString(w.writer, "\r\n") // end of header block
+	_, err := io.WriteString(w.writer, "\r\n")
 	return err
 }
 
+// writeFieldLines writes one "Name: value" line per value in h, names in
+// sorted order.
+func (w *Writer) writeFieldLines(h headers.Headers) error {
+	for k, v := range h.All() {
+		display := textproto.CanonicalMIMEHeaderKey(k)
+		if _, err := fmt.Fprintf(w.writer, "%s: %s\r\n", display, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func tokenListContains(list, token string) bool {
 	for t := range strings.SplitSeq(list, ",") {
 		if strings.TrimSpace(t) == token {
@@ -121,46 +331,224 @@ func tokenListContains(list, token string) bool {
 	return false
 }
 
-func (w *Writer) WriteBody(p []byte) (int, error) {
+// Write streams p as response body, sending the status line and headers
+// first if the handler has not. Without a Content-Length from the handler,
+// the body goes out chunked. Implements io.Writer.
+func (w *Writer) Write(p []byte) (int, error) {
+	if err := w.commit(); err != nil {
+		return 0, err
+	}
+	if w.chunked {
+		return w.WriteChunkedBody(p)
+	}
+	return w.WriteBody(p)
+}
+
+// commit sends whatever of the status line and headers is still pending,
+// for a body of not-yet-known length.
+func (w *Writer) commit() error {
+	if w.state() == WritingStatusLine {
+		// Check the handler's fields before anything goes out, so a bad one
+		// leaves the Writer untouched and the server can still answer 500.
+		if err := ValidateHeaders(w.Headers); err != nil {
+			return err
+		}
+		if w.Status == 0 {
+			w.Status = OK
+		}
+		if err := w.WriteStatusLine(w.Status); err != nil {
+			return err
+		}
+	}
+
+	if w.state() == WritingHeaders {
+		h := headers.NewHeaders()
+		if w.Headers.Get("content-length") == "" && w.Status.AllowsBody() {
+			h.Set("transfer-encoding", "chunked")
+		}
+		return w.WriteHeaders(h)
+	}
+	return nil
+}
 
+// Finish completes a streamed response: it sends pending headers, any
+// bytes still sitting in Body (dropped for 1xx/204/304), and the last
+// chunk of a chunked body.
+func (w *Writer) Finish() error {
+	if w.state() == WritingDone {
+		return nil
+	}
+	if err := w.commit(); err != nil {
+		return err
+	}
+	if len(w.Body) > 0 && w.Status.AllowsBody() {
+		body := w.Body
+		w.Body = nil
+		if _, err := w.Write(body); err != nil {
+			return err
+		}
+	}
+	if w.chunked {
+		_, err := w.WriteChunkedBodyDone()
+		return err
+	}
+	w.WriterStatus = WritingDone
+	return w.writer.Flush()
+}
+
+// Flush sends everything written so far to the client right away: pending
+// status line and headers, the partial chunk, and buffered bytes.
+func (w *Writer) Flush() error {
+	if w.state() == WritingDone {
+		return nil
+	}
+	if err := w.commit(); err != nil {
+		return err
+	}
+	if err := w.writeChunk(); err != nil {
+		return err
+	}
+	return w.writer.Flush()
+}
+
+func (w *Writer) WriteBody(p []byte) (int, error) {
+	if err := w.expect("WriteBody", WritingBody); err != nil {
+		return 0, err
+	}
+	if len(p) > 0 && !w.Status.AllowsBody() {
+		return 0, ErrBodyNotAllowed
+	}
+	if w.SuppressBody {
+		return len(p), nil
+	}
 	return w.writer.Write(p)
 }
 
 func (w *Writer) WriteChunkedBody(p []byte) (int, error) {
-	total := 0
-	for len(p) > 0 {
-		// take up to 1024 bytes
-		chunkSize := min(len(p), 1024)
-		chunk := p[:chunkSize]
-		p = p[chunkSize:]
+	if err := w.expect("WriteChunkedBody", WritingBody); err != nil {
+		return 0, err
+	}
+	if len(p) > 0 && !w.Status.AllowsBody() {
+		return 0, ErrBodyNotAllowed
+	}
+	if !w.chunked {
+		// Headers did not announce chunking (e.g. NoChunking for an
+		// HTTP/1.0 client), so framing the data would corrupt the body.
+		return w.WriteBody(p)
+	}
 
-		// write chunk size in hex followed by \r\n
-		if _, err := fmt.Fprintf(w.writer, "%x\r\n", len(chunk)); err != nil {
-			return total, err
+	if w.ChunkPerWrite {
+		// Send what earlier writes left buffered first, as its own chunk.
+		if err := w.writeChunk(); err != nil {
+			return 0, err
 		}
-
-		// write chunk data
-		n, err := w.writer.Write(chunk)
-		total += n
+		w.pending = p
+		err := w.writeChunk()
+		w.pending = nil
 		if err != nil {
-			return total, err
+			return 0, err
 		}
+		return len(p), nil
+	}
 
-		// write \r\n after chunk
-		if _, err := w.writer.Write([]byte("\r\n")); err != nil {
-			return total, err
+	size := w.ChunkSize
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+
+	// Collect data and send it in chunks of exactly size bytes.
+	w.pending = append(w.pending, p...)
+	for len(w.pending) >= size {
+		rest := w.pending[size:]
+		w.pending = w.pending[:size]
+		if err := w.writeChunk(); err != nil {
+			return 0, err
 		}
+		w.pending = append(w.pending[:0], rest...)
 	}
-	return total, nil
+	return len(p), nil
+}
+
+// writeChunk frames w.pending as one chunk (if non-empty) and clears it.
+func (w *Writer) writeChunk() error {
+	if len(w.pending) == 0 {
+		return nil
+	}
+	if w.SuppressBody {
+		w.pending = w.pending[:0]
+		return nil
+	}
+
+	// chunk size in hex, CRLF, chunk data, CRLF
+	if _, err := fmt.Fprintf(w.writer, "%x\r\n", len(w.pending)); err != nil {
+		return err
+	}
+	if _, err := w.writer.Write(w.pending); err != nil {
+		return err
+	}
+	if _, err := w.writer.WriteString("\r\n"); err != nil {
+		return err
+	}
+	w.pending = w.pending[:0]
+	return nil
 }
 
 // To finish the body, you need to send the terminating "0\r\n\r\n".
 func (w *Writer) Close() error {
-	_, err := w.writer.Write([]byte("0\r\n\r\n"))
+	_, err := w.WriteChunkedBodyDone()
 	return err
 }
 
+// WriteChunkedBodyDone sends the last chunk followed by w.Trailers.
 func (w *Writer) WriteChunkedBodyDone() (int, error) {
-	n, err := w.writer.Write([]byte("0\r\n\r\n"))
-	return n, err
+	if err := w.expect("WriteChunkedBodyDone", WritingBody); err != nil {
+		return 0, err
+	}
+	if !w.chunked {
+		return 0, nil // no last chunk to send; Finish ends the body
+	}
+	if len(w.Trailers) > 0 {
+		return 0, w.WriteTrailers(w.Trailers)
+	}
+	if err := w.writeChunk(); err != nil {
+		return 0, err
+	}
+	w.WriterStatus = WritingDone
+	if w.SuppressBody {
+		return 0, w.writer.Flush()
+	}
+	n, err := w.writer.WriteString("0\r\n\r\n")
+	if err != nil {
+		return n, err
+	}
+	return n, w.writer.Flush()
+}
+
+// WriteTrailers ends a chunked body: the last chunk, the trailer fields
+// in h, and the blank line closing the message.
+func (w *Writer) WriteTrailers(h headers.Headers) error {
+	if err := w.expect("WriteTrailers", WritingBody); err != nil {
+		return err
+	}
+	if err := ValidateHeaders(h); err != nil {
+		return err
+	}
+	if err := w.writeChunk(); err != nil {
+		return err
+	}
+	w.WriterStatus = WritingDone
+	if w.SuppressBody {
+		return w.writer.Flush()
+	}
+
+	if _, err := io.WriteString(w.writer, "0\r\n"); err != nil {
+		return err
+	}
+	if err := w.writeFieldLines(h); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w.writer, "\r\n"); err != nil {
+		return err
+	}
+	return w.writer.Flush()
 }
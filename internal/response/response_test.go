@@ -0,0 +1,451 @@
+package response
+
+import (
+	"bytes"
+	"httpfromtcp/internal/headers"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestWriter() (*Writer, *bytes.Buffer) {
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf)
+	w.Headers = headers.NewHeaders()
+	return w, buf
+}
+
+// bufferedHeaders is what the server passes to WriteHeaders for a buffered
+// body.
+func bufferedHeaders(body []byte) headers.Headers {
+	h := headers.NewHeaders()
+	h.Set("content-length", strconv.Itoa(len(body)))
+	h.Set("content-type", "text/plain")
+	return h
+}
+
+func TestWriterStreaming(t *testing.T) {
+	// Test: Write commits status and headers, then coalesces chunks
+	w, buf := newTestWriter()
+	w.Headers.Set("content-type", "text/html")
+	_, err := w.Write([]byte("hello"))
+	require.NoError(t, err)
+	assert.True(t, w.StatusWritten())
+	_, err = w.Write([]byte(" world"))
+	require.NoError(t, err)
+	require.NoError(t, w.Finish())
+	assert.Equal(t, "HTTP/1.1 200 OK\r\n"+
+		"Content-Type: text/html\r\n"+
+		"Transfer-Encoding: chunked\r\n"+
+		"\r\n"+
+		"b\r\nhello world\r\n"+
+		"0\r\n\r\n", buf.String())
+
+	// Test: Handler-provided Content-Length streams the body raw
+	w, buf = newTestWriter()
+	w.Status = BAD_REQUEST
+	w.Headers.Set("content-length", "3")
+	_, err = w.Write([]byte("bad"))
+	require.NoError(t, err)
+	require.NoError(t, w.Finish())
+	assert.Equal(t, "HTTP/1.1 400 Bad Request\r\n"+
+		"Content-Length: 3\r\n"+
+		"Content-Type: text/plain\r\n"+
+		"\r\n"+
+		"bad", buf.String())
+
+	// Test: Finish sends leftover Body after an explicit status line
+	w, buf = newTestWriter()
+	require.NoError(t, w.WriteStatusLine(OK))
+	w.SetBody([]byte("late"))
+	require.NoError(t, w.Finish())
+	require.NoError(t, w.Finish()) // idempotent
+	assert.Equal(t, "HTTP/1.1 200 OK\r\n"+
+		"Content-Type: text/plain\r\n"+
+		"Transfer-Encoding: chunked\r\n"+
+		"\r\n"+
+		"4\r\nlate\r\n"+
+		"0\r\n\r\n", buf.String())
+}
+
+func TestWriterStateMachine(t *testing.T) {
+	// Test: Body before headers is refused and writes nothing
+	w, buf := newTestWriter()
+	_, err := w.WriteBody([]byte("garbage"))
+	require.ErrorIs(t, err, ErrWriteOutOfOrder)
+	var serr *WriterStateError
+	require.ErrorAs(t, err, &serr)
+	assert.Equal(t, "WriteBody", serr.Op)
+	assert.Equal(t, WritingBody, serr.Want)
+	assert.Equal(t, WritingStatusLine, serr.Got)
+	assert.Empty(t, buf.String())
+
+	// Test: Headers before status line
+	require.ErrorIs(t, w.WriteHeaders(headers.NewHeaders()), ErrWriteOutOfOrder)
+
+	// Test: In-order calls advance the state
+	require.NoError(t, w.WriteStatusLine(OK))
+	assert.True(t, w.StatusWritten())
+	assert.False(t, w.HeadersWritten())
+	require.ErrorIs(t, w.WriteStatusLine(OK), ErrWriteOutOfOrder)
+
+	h := headers.NewHeaders()
+	h.Set("transfer-encoding", "chunked")
+	require.NoError(t, w.WriteHeaders(h))
+	assert.True(t, w.HeadersWritten())
+	assert.Equal(t, WritingBody, w.WriterStatus)
+
+	_, err = w.WriteChunkedBody([]byte("ok"))
+	require.NoError(t, err)
+	_, err = w.WriteChunkedBodyDone()
+	require.NoError(t, err)
+	assert.Equal(t, WritingDone, w.WriterStatus)
+
+	// Test: Nothing may follow the last chunk
+	_, err = w.WriteChunkedBody([]byte("late"))
+	require.ErrorIs(t, err, ErrWriteOutOfOrder)
+	_, err = w.Write([]byte("late"))
+	require.ErrorIs(t, err, ErrWriteOutOfOrder)
+	require.ErrorIs(t, w.Close(), ErrWriteOutOfOrder)
+	require.NoError(t, w.Finish())
+}
+
+func TestStatusCodes(t *testing.T) {
+	assert.Equal(t, "Content Too Large", CONTENT_TOO_LARGE.Reason())
+	assert.Equal(t, "Unknown", StatusCode(299).Reason())
+	assert.Equal(t, "418 Unknown", StatusCode(418).String())
+	assert.True(t, SWITCHING_PROTOCOLS.IsInformational())
+	assert.True(t, CREATED.IsSuccess())
+	assert.True(t, PERMANENT_REDIRECT.IsRedirect())
+	assert.True(t, TOO_MANY_REQUESTS.IsClientError())
+	assert.True(t, GATEWAY_TIMEOUT.IsServerError())
+	assert.False(t, NOT_FOUND.IsServerError())
+
+	assert.False(t, CONTINUE.AllowsBody())
+	assert.False(t, NO_CONTENT.AllowsBody())
+	assert.False(t, NOT_MODIFIED.AllowsBody())
+	assert.True(t, OK.AllowsBody())
+}
+
+func TestWriterBodylessStatus(t *testing.T) {
+	// Test: Buffered 204 drops framing headers and body
+	w, buf := newTestWriter()
+	w.SetBody([]byte("ignored"))
+	require.NoError(t, w.WriteStatusLine(NO_CONTENT))
+	require.NoError(t, w.WriteHeaders(bufferedHeaders(w.Body)))
+	require.NoError(t, w.Finish())
+	assert.Equal(t, "HTTP/1.1 204 No Content\r\n"+
+		"Content-Type: text/plain\r\n"+
+		"\r\n", buf.String())
+
+	// Test: Streaming content for a 304 is refused
+	w, buf = newTestWriter()
+	w.Status = NOT_MODIFIED
+	_, err := w.Write([]byte("nope"))
+	require.ErrorIs(t, err, ErrBodyNotAllowed)
+	require.NoError(t, w.Finish())
+	assert.Equal(t, "HTTP/1.1 304 Not Modified\r\n"+
+		"Content-Type: text/plain\r\n"+
+		"\r\n", buf.String())
+}
+
+func TestWriterTrailers(t *testing.T) {
+	// Test: Declared trailer is announced and sent after the last chunk
+	w, buf := newTestWriter()
+	require.NoError(t, w.DeclareTrailer("X-Content-SHA256", "X-Content-Length"))
+	_, err := w.Write([]byte("hello"))
+	require.NoError(t, err)
+	w.Trailers.Set("X-Content-SHA256", "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824")
+	w.Trailers.Set("X-Content-Length", "5")
+	require.NoError(t, w.Finish())
+	assert.Equal(t, "HTTP/1.1 200 OK\r\n"+
+		"Content-Type: text/plain\r\n"+
+		"Trailer: X-Content-SHA256,X-Content-Length\r\n"+
+		"Transfer-Encoding: chunked\r\n"+
+		"\r\n"+
+		"5\r\nhello\r\n"+
+		"0\r\n"+
+		"X-Content-Length: 5\r\n"+
+		"X-Content-Sha256: 2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824\r\n"+
+		"\r\n", buf.String())
+
+	// Test: Trailers turn a buffered Content-Length body into a chunked one
+	w, buf = newTestWriter()
+	require.NoError(t, w.DeclareTrailer("X-Count"))
+	w.SetBody([]byte("abc"))
+	w.Trailers.Set("X-Count", "3")
+	require.NoError(t, w.WriteStatusLine(OK))
+	require.NoError(t, w.WriteHeaders(bufferedHeaders(w.Body)))
+	require.NoError(t, w.Finish())
+	assert.Equal(t, "HTTP/1.1 200 OK\r\n"+
+		"Content-Type: text/plain\r\n"+
+		"Trailer: X-Count\r\n"+
+		"Transfer-Encoding: chunked\r\n"+
+		"\r\n"+
+		"3\r\nabc\r\n"+
+		"0\r\n"+
+		"X-Count: 3\r\n"+
+		"\r\n", buf.String())
+
+	// Test: Too late to declare once headers are out
+	require.ErrorIs(t, w.DeclareTrailer("X-Late"), ErrWriteOutOfOrder)
+}
+
+func TestWriterChunking(t *testing.T) {
+	// Test: Writes are cut into ChunkSize chunks, remainder on Finish
+	w, buf := newTestWriter()
+	w.ChunkSize = 4
+	_, err := w.Write([]byte("abcdefghij"))
+	require.NoError(t, err)
+	require.NoError(t, w.Finish())
+	assert.Equal(t, "HTTP/1.1 200 OK\r\n"+
+		"Content-Type: text/plain\r\n"+
+		"Transfer-Encoding: chunked\r\n"+
+		"\r\n"+
+		"4\r\nabcd\r\n"+
+		"4\r\nefgh\r\n"+
+		"2\r\nij\r\n"+
+		"0\r\n\r\n", buf.String())
+
+	// Test: ChunkPerWrite keeps handler writes as they are
+	w, buf = newTestWriter()
+	w.ChunkSize = 4
+	w.ChunkPerWrite = true
+	_, err = w.Write([]byte("data: one\n\n"))
+	require.NoError(t, err)
+	_, err = w.Write([]byte("x"))
+	require.NoError(t, err)
+	require.NoError(t, w.Finish())
+	assert.Contains(t, buf.String(), "\r\n\r\nb\r\ndata: one\n\n\r\n1\r\nx\r\n0\r\n\r\n")
+
+	// Test: Nothing reaches the connection until Flush
+	w, buf = newTestWriter()
+	_, err = w.Write([]byte("tick"))
+	require.NoError(t, err)
+	assert.Empty(t, buf.String())
+	require.NoError(t, w.Flush())
+	assert.True(t, strings.HasSuffix(buf.String(), "\r\n\r\n4\r\ntick\r\n"))
+	_, err = w.Write([]byte("tock"))
+	require.NoError(t, err)
+	require.NoError(t, w.Flush())
+	assert.True(t, strings.HasSuffix(buf.String(), "4\r\ntick\r\n4\r\ntock\r\n"))
+
+	// Test: Flush before any Write sends the headers
+	w, buf = newTestWriter()
+	require.NoError(t, w.Flush())
+	assert.Equal(t, "HTTP/1.1 200 OK\r\n"+
+		"Content-Type: text/plain\r\n"+
+		"Transfer-Encoding: chunked\r\n"+
+		"\r\n", buf.String())
+}
+
+func TestWriterSuppressBody(t *testing.T) {
+	// Test: Buffered HEAD keeps the GET Content-Length but no body
+	w, buf := newTestWriter()
+	w.SuppressBody = true
+	w.SetBody([]byte("hello"))
+	require.NoError(t, w.WriteStatusLine(OK))
+	require.NoError(t, w.WriteHeaders(bufferedHeaders(w.Body)))
+	require.NoError(t, w.Finish())
+	assert.Equal(t, "HTTP/1.1 200 OK\r\n"+
+		"Content-Length: 5\r\n"+
+		"Content-Type: text/plain\r\n"+
+		"\r\n", buf.String())
+
+	// Test: Streamed HEAD announces chunked but sends no chunks or trailers
+	w, buf = newTestWriter()
+	w.SuppressBody = true
+	require.NoError(t, w.DeclareTrailer("X-Count"))
+	_, err := w.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, w.Flush())
+	w.Trailers.Set("X-Count", "1")
+	require.NoError(t, w.Finish())
+	assert.Equal(t, "HTTP/1.1 200 OK\r\n"+
+		"Content-Type: text/plain\r\n"+
+		"Trailer: X-Count\r\n"+
+		"Transfer-Encoding: chunked\r\n"+
+		"\r\n", buf.String())
+}
+
+func TestWriterDefaults(t *testing.T) {
+	// Test: Defaults fill in fields nobody else set
+	w, buf := newTestWriter()
+	w.Defaults = headers.Headers{"date": {"Tue, 05 Mar 2024 08:07:03 GMT"}, "server": {"test"}}
+	w.Headers.Set("server", "handler")
+	_, err := w.Write([]byte("hi"))
+	require.NoError(t, err)
+	require.NoError(t, w.Finish())
+	assert.Equal(t, "HTTP/1.1 200 OK\r\n"+
+		"Date: Tue, 05 Mar 2024 08:07:03 GMT\r\n"+
+		"Server: handler\r\n"+
+		"Transfer-Encoding: chunked\r\n"+
+		"\r\n"+
+		"2\r\nhi\r\n"+
+		"0\r\n\r\n", buf.String())
+}
+
+func TestWriterMultiValueHeaders(t *testing.T) {
+	// Test: Every value goes out on its own line
+	w, buf := newTestWriter()
+	w.Defaults = nil
+	w.Headers.Add("Set-Cookie", "a=1; Path=/")
+	w.Headers.Add("Set-Cookie", "b=2; Expires=Wed, 21 Oct 2015 07:28:00 GMT")
+	w.SetBody([]byte("ok"))
+	require.NoError(t, w.WriteStatusLine(OK))
+	h := headers.NewHeaders()
+	h.Set("content-length", "2")
+	require.NoError(t, w.WriteHeaders(h))
+	require.NoError(t, w.Finish())
+	assert.Equal(t, "HTTP/1.1 200 OK\r\n"+
+		"Content-Length: 2\r\n"+
+		"Set-Cookie: a=1; Path=/\r\n"+
+		"Set-Cookie: b=2; Expires=Wed, 21 Oct 2015 07:28:00 GMT\r\n"+
+		"\r\n"+
+		"ok", buf.String())
+}
+
+func TestWriterRawHeaders(t *testing.T) {
+	// Test: Raw fields go out verbatim, with nothing added
+	w, buf := newTestWriter()
+	w.Headers.Set("x-ignored", "1")
+	require.NoError(t, w.WriteStatusLine(OK))
+	require.NoError(t, w.WriteRawHeaders(headers.RawHeaders{
+		{Name: "x-upstream", Value: "a"},
+		{Name: "Content-TYPE", Value: "text/html"},
+		{Name: "transfer-encoding", Value: "chunked"},
+	}))
+	_, err := w.Write([]byte("hi"))
+	require.NoError(t, err)
+	require.NoError(t, w.Finish())
+	assert.Equal(t, "HTTP/1.1 200 OK\r\n"+
+		"x-upstream: a\r\n"+
+		"Content-TYPE: text/html\r\n"+
+		"transfer-encoding: chunked\r\n"+
+		"\r\n"+
+		"2\r\nhi\r\n"+
+		"0\r\n\r\n", buf.String())
+
+	require.ErrorIs(t, w.WriteRawHeaders(nil), ErrWriteOutOfOrder)
+}
+
+func TestWriterRejectsInvalidHeaders(t *testing.T) {
+	// Test: CR/LF in a value is refused before any field line is written
+	w, buf := newTestWriter()
+	w.Headers.Set("location", "/next\r\nSet-Cookie: evil=1")
+	require.NoError(t, w.WriteStatusLine(FOUND))
+	err := w.WriteHeaders(headers.NewHeaders())
+	require.ErrorIs(t, err, ErrInvalidHeader)
+	var herr *HeaderError
+	require.ErrorAs(t, err, &herr)
+	assert.Equal(t, "location", herr.Name)
+	assert.False(t, w.HeadersWritten())
+	assert.Empty(t, buf.String()) // status line still buffered
+
+	// Test: A corrected call goes through
+	w.Headers.Set("location", "/next")
+	require.NoError(t, w.WriteHeaders(headers.NewHeaders()))
+	require.NoError(t, w.Finish())
+	assert.Equal(t, "HTTP/1.1 302 Found\r\n"+
+		"Content-Type: text/plain\r\n"+
+		"Location: /next\r\n"+
+		"\r\n", buf.String())
+
+	// Test: Streaming with a bad field writes nothing, not even the status line
+	w, buf = newTestWriter()
+	w.Headers.Set("x-bad", "a\r\nb")
+	_, err = w.Write([]byte("body"))
+	require.ErrorIs(t, err, ErrInvalidHeader)
+	assert.False(t, w.StatusWritten())
+	require.ErrorIs(t, w.Flush(), ErrInvalidHeader)
+	assert.Empty(t, buf.String())
+
+	// Test: Bad names, raw fields and trailers are checked too
+	w, _ = newTestWriter()
+	w.Headers.Set("x bad", "1")
+	require.NoError(t, w.WriteStatusLine(OK))
+	require.ErrorIs(t, w.WriteHeaders(headers.NewHeaders()), ErrInvalidHeader)
+	require.ErrorIs(t, w.WriteRawHeaders(headers.RawHeaders{{Name: "X-A", Value: "1\n2"}}), ErrInvalidHeader)
+
+	w, _ = newTestWriter()
+	_, err = w.Write([]byte("x"))
+	require.NoError(t, err)
+	bad := headers.NewHeaders()
+	bad.Set("x-sum", "1\r\n")
+	require.ErrorIs(t, w.WriteTrailers(bad), ErrInvalidHeader)
+}
+
+func TestWriterNoChunking(t *testing.T) {
+	// Test: Unknown length goes out close-delimited, trailers dropped
+	w, buf := newTestWriter()
+	w.NoChunking = true
+	require.NoError(t, w.DeclareTrailer("X-Count"))
+	_, err := w.Write([]byte("hello "))
+	require.NoError(t, err)
+	_, err = w.Write([]byte("world"))
+	require.NoError(t, err)
+	w.Trailers.Set("X-Count", "2")
+	require.NoError(t, w.Finish())
+	assert.True(t, w.CloseDelimited())
+	assert.Equal(t, "HTTP/1.1 200 OK\r\n"+
+		"Connection: close\r\n"+
+		"Content-Type: text/plain\r\n"+
+		"\r\n"+
+		"hello world", buf.String())
+
+	// Test: A known Content-Length is kept, and the connection with it
+	w, buf = newTestWriter()
+	w.NoChunking = true
+	w.Headers.Set("content-length", "2")
+	_, err = w.Write([]byte("ok"))
+	require.NoError(t, err)
+	require.NoError(t, w.Finish())
+	assert.False(t, w.CloseDelimited())
+	assert.Equal(t, "HTTP/1.1 200 OK\r\n"+
+		"Content-Length: 2\r\n"+
+		"Content-Type: text/plain\r\n"+
+		"\r\n"+
+		"ok", buf.String())
+}
+
+func TestWriterNoChunkingDirectCalls(t *testing.T) {
+	// Test: Chunked write calls fall back to raw body bytes
+	w, buf := newTestWriter()
+	w.NoChunking = true
+	require.NoError(t, w.WriteStatusLine(OK))
+	h := headers.NewHeaders()
+	h.Set("transfer-encoding", "chunked")
+	require.NoError(t, w.WriteHeaders(h))
+	_, err := w.WriteChunkedBody([]byte("hello"))
+	require.NoError(t, err)
+	_, err = w.WriteChunkedBodyDone()
+	require.NoError(t, err)
+	require.NoError(t, w.Finish())
+	assert.True(t, w.CloseDelimited())
+	assert.Equal(t, "HTTP/1.1 200 OK\r\n"+
+		"Connection: close\r\n"+
+		"Content-Type: text/plain\r\n"+
+		"\r\n"+
+		"hello", buf.String())
+}
+
+func TestWriterChunkPerWriteKeepsPending(t *testing.T) {
+	// Test: Switching to ChunkPerWrite sends what was already buffered
+	w, buf := newTestWriter()
+	_, err := w.Write([]byte("abc"))
+	require.NoError(t, err)
+	w.ChunkPerWrite = true
+	_, err = w.Write([]byte("def"))
+	require.NoError(t, err)
+	require.NoError(t, w.Finish())
+	assert.Equal(t, "HTTP/1.1 200 OK\r\n"+
+		"Content-Type: text/plain\r\n"+
+		"Transfer-Encoding: chunked\r\n"+
+		"\r\n"+
+		"3\r\nabc\r\n"+
+		"3\r\ndef\r\n"+
+		"0\r\n\r\n", buf.String())
+}
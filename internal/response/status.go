@@ -0,0 +1,168 @@
+package response
+
+import "fmt"
+
+type StatusCode int
+
+// Status codes registered with IANA (RFC 9110 section 15 and extensions).
+const (
+	// Informational 1xx
+	CONTINUE            StatusCode = 100
+	SWITCHING_PROTOCOLS StatusCode = 101
+	PROCESSING          StatusCode = 102
+	EARLY_HINTS         StatusCode = 103
+
+	// Successful 2xx
+	OK                            StatusCode = 200
+	CREATED                       StatusCode = 201
+	ACCEPTED                      StatusCode = 202
+	NON_AUTHORITATIVE_INFORMATION StatusCode = 203
+	NO_CONTENT                    StatusCode = 204
+	RESET_CONTENT                 StatusCode = 205
+	PARTIAL_CONTENT               StatusCode = 206
+	MULTI_STATUS                  StatusCode = 207
+	ALREADY_REPORTED              StatusCode = 208
+	IM_USED                       StatusCode = 226
+
+	// Redirection 3xx
+	MULTIPLE_CHOICES   StatusCode = 300
+	MOVED_PERMANENTLY  StatusCode = 301
+	FOUND              StatusCode = 302
+	SEE_OTHER          StatusCode = 303
+	NOT_MODIFIED       StatusCode = 304
+	USE_PROXY          StatusCode = 305
+	TEMPORARY_REDIRECT StatusCode = 307
+	PERMANENT_REDIRECT StatusCode = 308
+
+	// Client Error 4xx
+	BAD_REQUEST                     StatusCode = 400
+	UNAUTHORIZED                    StatusCode = 401
+	PAYMENT_REQUIRED                StatusCode = 402
+	FORBIDDEN                       StatusCode = 403
+	NOT_FOUND                       StatusCode = 404
+	METHOD_NOT_ALLOWED              StatusCode = 405
+	NOT_ACCEPTABLE                  StatusCode = 406
+	PROXY_AUTHENTICATION_REQUIRED   StatusCode = 407
+	REQUEST_TIMEOUT                 StatusCode = 408
+	CONFLICT                        StatusCode = 409
+	GONE                            StatusCode = 410
+	LENGTH_REQUIRED                 StatusCode = 411
+	PRECONDITION_FAILED             StatusCode = 412
+	CONTENT_TOO_LARGE               StatusCode = 413
+	URI_TOO_LONG                    StatusCode = 414
+	UNSUPPORTED_MEDIA_TYPE          StatusCode = 415
+	RANGE_NOT_SATISFIABLE           StatusCode = 416
+	EXPECTATION_FAILED              StatusCode = 417
+	MISDIRECTED_REQUEST             StatusCode = 421
+	UNPROCESSABLE_CONTENT           StatusCode = 422
+	LOCKED                          StatusCode = 423
+	FAILED_DEPENDENCY               StatusCode = 424
+	TOO_EARLY                       StatusCode = 425
+	UPGRADE_REQUIRED                StatusCode = 426
+	PRECONDITION_REQUIRED           StatusCode = 428
+	TOO_MANY_REQUESTS               StatusCode = 429
+	REQUEST_HEADER_FIELDS_TOO_LARGE StatusCode = 431
+	UNAVAILABLE_FOR_LEGAL_REASONS   StatusCode = 451
+
+	// Server Error 5xx
+	INTERNAL_SERVER_ERROR           StatusCode = 500
+	NOT_IMPLEMENTED                 StatusCode = 501
+	BAD_GATEWAY                     StatusCode = 502
+	SERVICE_UNAVAILABLE             StatusCode = 503
+	GATEWAY_TIMEOUT                 StatusCode = 504
+	HTTP_VERSION_NOT_SUPPORTED      StatusCode = 505
+	VARIANT_ALSO_NEGOTIATES         StatusCode = 506
+	INSUFFICIENT_STORAGE            StatusCode = 507
+	LOOP_DETECTED                   StatusCode = 508
+	NOT_EXTENDED                    StatusCode = 510
+	NETWORK_AUTHENTICATION_REQUIRED StatusCode = 511
+)
+
+var StatusCodeName = map[StatusCode]string{
+	CONTINUE:                        "Continue",
+	SWITCHING_PROTOCOLS:             "Switching Protocols",
+	PROCESSING:                      "Processing",
+	EARLY_HINTS:                     "Early Hints",
+	OK:                              "OK",
+	CREATED:                         "Created",
+	ACCEPTED:                        "Accepted",
+	NON_AUTHORITATIVE_INFORMATION:   "Non-Authoritative Information",
+	NO_CONTENT:                      "No Content",
+	RESET_CONTENT:                   "Reset Content",
+	PARTIAL_CONTENT:                 "Partial Content",
+	MULTI_STATUS:                    "Multi-Status",
+	ALREADY_REPORTED:                "Already Reported",
+	IM_USED:                         "IM Used",
+	MULTIPLE_CHOICES:                "Multiple Choices",
+	MOVED_PERMANENTLY:               "Moved Permanently",
+	FOUND:                           "Found",
+	SEE_OTHER:                       "See Other",
+	NOT_MODIFIED:                    "Not Modified",
+	USE_PROXY:                       "Use Proxy",
+	TEMPORARY_REDIRECT:              "Temporary Redirect",
+	PERMANENT_REDIRECT:              "Permanent Redirect",
+	BAD_REQUEST:                     "Bad Request",
+	UNAUTHORIZED:                    "Unauthorized",
+	PAYMENT_REQUIRED:                "Payment Required",
+	FORBIDDEN:                       "Forbidden",
+	NOT_FOUND:                       "Not Found",
+	METHOD_NOT_ALLOWED:              "Method Not Allowed",
+	NOT_ACCEPTABLE:                  "Not Acceptable",
+	PROXY_AUTHENTICATION_REQUIRED:   "Proxy Authentication Required",
+	REQUEST_TIMEOUT:                 "Request Timeout",
+	CONFLICT:                        "Conflict",
+	GONE:                            "Gone",
+	LENGTH_REQUIRED:                 "Length Required",
+	PRECONDITION_FAILED:             "Precondition Failed",
+	CONTENT_TOO_LARGE:               "Content Too Large",
+	URI_TOO_LONG:                    "URI Too Long",
+	UNSUPPORTED_MEDIA_TYPE:          "Unsupported Media Type",
+	RANGE_NOT_SATISFIABLE:           "Range Not Satisfiable",
+	EXPECTATION_FAILED:              "Expectation Failed",
+	MISDIRECTED_REQUEST:             "Misdirected Request",
+	UNPROCESSABLE_CONTENT:           "Unprocessable Content",
+	LOCKED:                          "Locked",
+	FAILED_DEPENDENCY:               "Failed Dependency",
+	TOO_EARLY:                       "Too Early",
+	UPGRADE_REQUIRED:                "Upgrade Required",
+	PRECONDITION_REQUIRED:           "Precondition Required",
+	TOO_MANY_REQUESTS:               "Too Many Requests",
+	REQUEST_HEADER_FIELDS_TOO_LARGE: "Request Header Fields Too Large",
+	UNAVAILABLE_FOR_LEGAL_REASONS:   "Unavailable For Legal Reasons",
+	INTERNAL_SERVER_ERROR:           "Internal Server Error",
+	NOT_IMPLEMENTED:                 "Not Implemented",
+	BAD_GATEWAY:                     "Bad Gateway",
+	SERVICE_UNAVAILABLE:             "Service Unavailable",
+	GATEWAY_TIMEOUT:                 "Gateway Timeout",
+	HTTP_VERSION_NOT_SUPPORTED:      "HTTP Version Not Supported",
+	VARIANT_ALSO_NEGOTIATES:         "Variant Also Negotiates",
+	INSUFFICIENT_STORAGE:            "Insufficient Storage",
+	LOOP_DETECTED:                   "Loop Detected",
+	NOT_EXTENDED:                    "Not Extended",
+	NETWORK_AUTHENTICATION_REQUIRED: "Network Authentication Required",
+}
+
+// Reason returns the registered reason phrase, or "Unknown".
+func (c StatusCode) Reason() string {
+	if reason, ok := StatusCodeName[c]; ok {
+		return reason
+	}
+	return "Unknown"
+}
+
+func (c StatusCode) String() string {
+	return fmt.Sprintf("%d %s", int(c), c.Reason())
+}
+
+func (c StatusCode) IsInformational() bool { return c >= 100 && c < 200 }
+func (c StatusCode) IsSuccess() bool       { return c >= 200 && c < 300 }
+func (c StatusCode) IsRedirect() bool      { return c >= 300 && c < 400 }
+func (c StatusCode) IsClientError() bool   { return c >= 400 && c < 500 }
+func (c StatusCode) IsServerError() bool   { return c >= 500 && c < 600 }
+
+// AllowsBody reports whether a response with this status may carry content.
+// 1xx, 204 and 304 responses end right after the header section
+// (RFC 9112 section 6.3).
+func (c StatusCode) AllowsBody() bool {
+	return !c.IsInformational() && c != NO_CONTENT && c != NOT_MODIFIED
+}
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Date header format (IMF-fixdate, RFC 9110 5.6.7).
+const dateFormat = "Mon, 02 Jan 2006 15:04:05 GMT"
+
+// dateClock caches the formatted Date value so busy servers format it at
+// most once per second instead of once per response.
+type dateClock struct {
+	cached atomic.Pointer[dateValue]
+	clock  func() time.Time
+}
+
+type dateValue struct {
+	unix  int64
+	value string
+}
+
+var httpDate = &dateClock{clock: time.Now}
+
+func (c *dateClock) now() string {
+	t := c.clock()
+	if d := c.cached.Load(); d != nil && d.unix == t.Unix() {
+		return d.value
+	}
+	d := &dateValue{unix: t.Unix(), value: t.UTC().Format(dateFormat)}
+	c.cached.Store(d)
+	return d.value
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDateClock(t *testing.T) {
+	now := time.Date(2024, time.March, 5, 9, 7, 3, 0, time.FixedZone("CET", 3600))
+	c := &dateClock{clock: func() time.Time { return now }}
+
+	assert.Equal(t, "Tue, 05 Mar 2024 08:07:03 GMT", c.now())
+
+	// Within the same second the cached value is reused.
+	first := c.cached.Load()
+	now = now.Add(500 * time.Millisecond)
+	assert.Equal(t, "Tue, 05 Mar 2024 08:07:03 GMT", c.now())
+	assert.Same(t, first, c.cached.Load())
+
+	now = now.Add(time.Second)
+	assert.Equal(t, "Tue, 05 Mar 2024 08:07:04 GMT", c.now())
+}
+
+func TestConfigDefaultHeaders(t *testing.T) {
+	h := Config{}.defaultHeaders()
+	assert.NotEmpty(t, h.Get("date"))
+	assert.Equal(t, "text/plain", h.Get("content-type"))
+	assert.Empty(t, h.Get("server"))
+
+	h = Config{ServerName: "httpfromtcp", DefaultContentType: "application/json"}.defaultHeaders()
+	assert.Equal(t, "httpfromtcp", h.Get("server"))
+	assert.Equal(t, "application/json", h.Get("content-type"))
+
+	h = Config{OmitContentType: true}.defaultHeaders()
+	assert.Empty(t, h.Get("content-type"))
+}
+
+func TestServeConfigRejectsBadDefaults(t *testing.T) {
+	noop := func(w *response.Writer, req *request.Request) {}
+
+	_, err := ServeConfig(0, noop, Config{ServerName: "x\r\ny: z"})
+	require.ErrorIs(t, err, response.ErrInvalidHeader)
+	_, err = ServeConfig(0, noop, Config{DefaultContentType: "text/plain\nx: y"})
+	require.ErrorIs(t, err, response.ErrInvalidHeader)
+
+	s, err := ServeConfig(0, noop, Config{ServerName: "httpfromtcp/1.0"})
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"httpfromtcp/internal/headers"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// HandlerError is an error a handler returns to have the server answer
+// with StatusCode and Message instead of building the response itself.
+type HandlerError struct {
+	StatusCode response.StatusCode
+	Message    string
+}
+
+func (e *HandlerError) Error() string {
+	return fmt.Sprintf("%v: %s", e.StatusCode, e.Message)
+}
+
+// ErrorHandler is a Handler that can fail. A *HandlerError is rendered with
+// its own status and message; any other error becomes a 500 whose details
+// are only logged.
+type ErrorHandler func(w *response.Writer, req *request.Request) error
+
+// HandleErrors adapts an ErrorHandler to a Handler.
+func HandleErrors(h ErrorHandler) Handler {
+	return func(w *response.Writer, req *request.Request) {
+		if err := h(w, req); err != nil {
+			writeHandlerError(w, req, err)
+		}
+	}
+}
+
+// writeHandlerError logs err and replaces the response with an error page in
+// whichever of HTML, JSON or plain text the client prefers.
+func writeHandlerError(w *response.Writer, req *request.Request, err error) {
+	var herr *HandlerError
+	if !errors.As(err, &herr) {
+		herr = &HandlerError{StatusCode: response.INTERNAL_SERVER_ERROR, Message: "Something went wrong on our side."}
+	}
+
+	remoteHost, _, _ := net.SplitHostPort(req.RemoteAddr)
+	log.Printf("%s\t%s\t%s\t%d\terr=%q",
+		remoteHost, req.RequestLine.Method, req.RequestLine.RequestTarget, int(herr.StatusCode), err.Error(),
+	)
+
+	if w.StatusWritten() {
+		return // too late to change the response; the log line is all we can do
+	}
+
+	reason := herr.StatusCode.Reason()
+	var body string
+	contentType := negotiateErrorType(req.Headers.Get("accept"))
+	switch contentType {
+	case "text/html":
+		body = fmt.Sprintf(`<html>
+  <head>
+    <title>%d %s</title>
+  </head>
+  <body>
+    <h1>%s</h1>
+    <p>%s</p>
+  </body>
+</html>`, int(herr.StatusCode), reason, reason, html.EscapeString(herr.Message))
+	case "application/json":
+		b, _ := json.Marshal(struct {
+			Status  int    `json:"status"`
+			Error   string `json:"error"`
+			Message string `json:"message"`
+		}{int(herr.StatusCode), reason, herr.Message})
+		body = string(b)
+	default:
+		body = fmt.Sprintf("%d %s\n%s\n", int(herr.StatusCode), reason, herr.Message)
+	}
+
+	// Start from clean headers: framing the handler set up (Content-Length,
+	// Trailer) describes a body that is not the one being sent. Only a
+	// Connection decision survives.
+	conn := w.Headers.Values("connection")
+	w.Headers = headers.NewHeaders()
+	if len(conn) > 0 {
+		w.Headers["connection"] = conn
+	}
+	w.Trailers = headers.NewHeaders()
+
+	w.Status = herr.StatusCode
+	w.Headers.Set("content-type", contentType)
+	w.SetBody([]byte(body))
+}
+
+// Media types we can render errors as, most preferred first on ties.
+var errorTypes = []string{"text/plain", "text/html", "application/json"}
+
+// negotiateErrorType picks the error media type with the highest q-value in
+// an Accept header (RFC 9110 12.5.1). Without a usable Accept, plain text.
+func negotiateErrorType(accept string) string {
+	best, bestQ := errorTypes[0], 0.0
+	for _, t := range errorTypes {
+		if q := acceptQuality(accept, t); q > bestQ {
+			best, bestQ = t, q
+		}
+	}
+	return best
+}
+
+// acceptQuality returns the q-value accept gives to mediaType, using the
+// most specific matching range; 0 if none matches.
+func acceptQuality(accept, mediaType string) float64 {
+	typ, _, _ := strings.Cut(mediaType, "/")
+	q, specificity := 0.0, -1
+
+	for r := range strings.SplitSeq(accept, ",") {
+		params := strings.Split(r, ";")
+		rng := strings.ToLower(strings.TrimSpace(params[0]))
+
+		s := -1
+		switch rng {
+		case mediaType:
+			s = 2
+		case typ + "/*":
+			s = 1
+		case "*/*":
+			s = 0
+		}
+		if s <= specificity {
+			continue
+		}
+
+		rq := 1.0
+		for _, p := range params[1:] {
+			k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+			if strings.EqualFold(k, "q") {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					rq = f
+				}
+			}
+		}
+		q, specificity = rq, s
+	}
+	return q
+}
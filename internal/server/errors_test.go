@@ -0,0 +1,107 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"httpfromtcp/internal/headers"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateErrorType(t *testing.T) {
+	assert.Equal(t, "text/plain", negotiateErrorType(""))
+	assert.Equal(t, "text/plain", negotiateErrorType("*/*"))
+	assert.Equal(t, "text/html", negotiateErrorType("text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"))
+	assert.Equal(t, "application/json", negotiateErrorType("application/json"))
+	assert.Equal(t, "application/json", negotiateErrorType("text/*;q=0.5, application/json"))
+	assert.Equal(t, "text/html", negotiateErrorType("text/plain;q=0, text/*"))
+	assert.Equal(t, "text/plain", negotiateErrorType("image/png"))
+}
+
+func TestHandleErrors(t *testing.T) {
+	serve := func(accept string, err error) *response.Writer {
+		req, perr := request.RequestFromReader(strings.NewReader("GET /x HTTP/1.1\r\nAccept: " + accept + "\r\n\r\n"))
+		require.NoError(t, perr)
+		w := response.NewWriter(&bytes.Buffer{})
+		w.Headers = headers.NewHeaders()
+		HandleErrors(func(w *response.Writer, req *request.Request) error {
+			w.Status = response.OK
+			return err
+		})(w, req)
+		return w
+	}
+
+	// Test: HandlerError rendered as JSON
+	w := serve("application/json", &HandlerError{StatusCode: response.NOT_FOUND, Message: "no such user"})
+	assert.Equal(t, response.NOT_FOUND, w.Status)
+	assert.Equal(t, "application/json", w.Headers.Get("content-type"))
+	assert.JSONEq(t, `{"status":404,"error":"Not Found","message":"no such user"}`, string(w.Body))
+
+	// Test: HandlerError rendered as HTML, message escaped
+	w = serve("text/html", &HandlerError{StatusCode: response.BAD_REQUEST, Message: "<b>bad</b>"})
+	assert.Equal(t, response.BAD_REQUEST, w.Status)
+	assert.Contains(t, string(w.Body), "<h1>Bad Request</h1>")
+	assert.Contains(t, string(w.Body), "&lt;b&gt;bad&lt;/b&gt;")
+
+	// Test: Plain error becomes a 500 without leaking details
+	w = serve("*/*", errors.New("db password is hunter2"))
+	assert.Equal(t, response.INTERNAL_SERVER_ERROR, w.Status)
+	assert.Equal(t, "text/plain", w.Headers.Get("content-type"))
+	assert.NotContains(t, string(w.Body), "hunter2")
+
+	// Test: No error leaves the response alone
+	w = serve("*/*", nil)
+	assert.Equal(t, response.OK, w.Status)
+	assert.Empty(t, w.Body)
+}
+
+func TestHandleErrorsResetsFraming(t *testing.T) {
+	serve := func(setup func(w *response.Writer)) (*response.Writer, string) {
+		req, err := request.RequestFromReader(strings.NewReader("GET /x HTTP/1.1\r\n\r\n"))
+		require.NoError(t, err)
+		buf := &bytes.Buffer{}
+		w := response.NewWriter(buf)
+		w.Headers = headers.NewHeaders()
+		w.Headers.Set("connection", "close")
+		HandleErrors(func(w *response.Writer, req *request.Request) error {
+			setup(w)
+			return &HandlerError{StatusCode: response.NOT_FOUND, Message: "gone"}
+		})(w, req)
+
+		// Send it the way the server's buffered path does.
+		require.NoError(t, w.WriteStatusLine(w.Status))
+		h := headers.NewHeaders()
+		h.Set("content-length", strconv.Itoa(len(w.Body)))
+		require.NoError(t, w.WriteHeaders(h))
+		require.NoError(t, w.Finish())
+		return w, buf.String()
+	}
+	want := "HTTP/1.1 404 Not Found\r\n" +
+		"Connection: close\r\n" +
+		"Content-Length: 19\r\n" +
+		"Content-Type: text/plain\r\n" +
+		"\r\n" +
+		"404 Not Found\ngone\n"
+
+	// Test: A Content-Length set before failing does not survive
+	w, out := serve(func(w *response.Writer) {
+		w.Headers.Set("content-length", "1000")
+		w.Headers.Set("x-partial", "1")
+	})
+	assert.Empty(t, w.Headers.Get("x-partial"))
+	assert.Equal(t, want, out)
+
+	// Test: Declared trailers do not turn the error page chunked
+	w, out = serve(func(w *response.Writer) {
+		require.NoError(t, w.DeclareTrailer("X-Sum"))
+		w.Trailers.Set("X-Sum", "abc")
+	})
+	assert.Empty(t, w.Trailers)
+	assert.Equal(t, want, out)
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"log"
+	"net"
+	"time"
+)
+
+// Middleware wraps a Handler with cross-cutting behavior (logging, auth,
+// recovery...). It may act before and after calling next, or not call it.
+type Middleware func(next Handler) Handler
+
+// Chain wraps h with mws so that the first middleware is the outermost:
+// Chain(h, a, b) handles a request as a(b(h)).
+func Chain(h Handler, mws ...Middleware) Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// AccessLog logs one line per handled request:
+//
+//	<remote host>	<method>	<target>	<status>	<handler duration>
+//
+// Requests that fail before reaching a handler (parse errors, timeouts)
+// and response write failures are still logged by the server itself.
+func AccessLog(next Handler) Handler {
+	return func(w *response.Writer, req *request.Request) {
+		start := time.Now()
+		next(w, req)
+
+		remoteHost, _, _ := net.SplitHostPort(req.RemoteAddr)
+		log.Printf("%s\t%s\t%s\t%d\t%s",
+			remoteHost, req.RequestLine.Method, req.RequestLine.RequestTarget,
+			int(w.Status), fmtDur(time.Since(start)),
+		)
+	}
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"bytes"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChainOrder(t *testing.T) {
+	var calls []string
+	mw := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(w *response.Writer, req *request.Request) {
+				calls = append(calls, name+":before")
+				next(w, req)
+				calls = append(calls, name+":after")
+			}
+		}
+	}
+
+	h := Chain(func(w *response.Writer, req *request.Request) {
+		calls = append(calls, "handler")
+	}, mw("a"), mw("b"))
+	h(nil, nil)
+
+	assert.Equal(t, []string{"a:before", "b:before", "handler", "b:after", "a:after"}, calls)
+}
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	flags, out := log.Flags(), log.Writer()
+	log.SetFlags(0)
+	log.SetOutput(&buf)
+	defer func() {
+		log.SetFlags(flags)
+		log.SetOutput(out)
+	}()
+
+	req, err := request.RequestFromReader(strings.NewReader("DELETE /items/7?force=1 HTTP/1.1\r\nHost: x\r\n\r\n"))
+	require.NoError(t, err)
+	req.RemoteAddr = "192.0.2.10:51234"
+
+	h := AccessLog(func(w *response.Writer, req *request.Request) {
+		w.Status = response.NOT_FOUND
+	})
+	h(response.NewWriter(&bytes.Buffer{}), req)
+
+	// Test: One line with host, method, target, status and duration
+	var line string
+	for l := range strings.Lines(buf.String()) {
+		if strings.Contains(l, "DELETE") { // skip stray lines from other tests' servers
+			line = strings.TrimSuffix(l, "\n")
+		}
+	}
+	fields := strings.Split(line, "\t")
+	require.Len(t, fields, 5)
+	assert.Equal(t, []string{"192.0.2.10", "DELETE", "/items/7?force=1", "404"}, fields[:4])
+	assert.True(t, strings.HasSuffix(fields[4], "ms"))
+}
+
+// lockedBuffer is a log output safe to read while servers write to it.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestAccessLogDefaultStatus(t *testing.T) {
+	var buf lockedBuffer
+	flags, out := log.Flags(), log.Writer()
+	log.SetFlags(0)
+	log.SetOutput(&buf)
+	defer func() {
+		log.SetFlags(flags)
+		log.SetOutput(out)
+	}()
+
+	h := Chain(func(w *response.Writer, req *request.Request) {
+		w.SetBody([]byte("no status set"))
+	}, AccessLog)
+	_, addr := startServer(t, h, Config{})
+	conn, br := dial(t, addr)
+
+	// Test: A handler that sets no status is logged with the 200 it gets
+	_, err := io.WriteString(conn, "GET /default-status HTTP/1.1\r\nHost: x\r\n\r\n")
+	require.NoError(t, err)
+	resp, _ := readResponse(t, br, "GET")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var line string
+	for l := range strings.Lines(buf.String()) {
+		if strings.Contains(l, "/default-status") {
+			line = l
+		}
+	}
+	fields := strings.Split(line, "\t")
+	require.Len(t, fields, 5)
+	assert.Equal(t, []string{"127.0.0.1", "GET", "/default-status", "200"}, fields[:4])
+}
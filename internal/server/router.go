@@ -0,0 +1,268 @@
+package server
+
+import (
+	"fmt"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"slices"
+	"strings"
+)
+
+// Router dispatches requests to handlers registered by method and path
+// pattern, answering 404 Not Found and 405 Method Not Allowed itself.
+//
+// Patterns look like "[METHOD ]/path/{param}/{rest...}":
+//   - a literal segment matches itself exactly;
+//   - "{name}" matches any single segment;
+//   - "{name...}" (last segment only) matches the remainder of the path.
+//
+// Without a method, a pattern matches every method. When several patterns
+// match, the most specific wins, comparing segment by segment (literal
+// before "{name}" before "{name...}").
+type Router struct {
+	routes []*route
+}
+
+type route struct {
+	method   string // "" = any method
+	pattern  string
+	segments []segment
+	handler  Handler
+	limits   request.Limits // zero = the server's
+}
+
+type segmentKind int
+
+const (
+	segLiteral  segmentKind = iota // "users"
+	segParam                       // "{id}"
+	segWildcard                    // "{path...}"
+)
+
+type segment struct {
+	kind  segmentKind
+	value string // literal text or parameter name
+}
+
+func NewRouter() *Router {
+	return &Router{}
+}
+
+// Handle registers handler for pattern. It panics on a malformed or
+// duplicate pattern, since that is a programming error.
+func (rt *Router) Handle(pattern string, handler Handler) {
+	rt.HandleLimits(pattern, request.Limits{}, handler)
+}
+
+// HandleLimits is Handle with request limits for the route, which take
+// effect when the server's Config.RouteLimits is set to rt.Limits. Zero
+// fields keep the server's limits; start-line and header limits can only
+// be tightened, since those parts are read before the route is known.
+func (rt *Router) HandleLimits(pattern string, limits request.Limits, handler Handler) {
+	method, path, found := strings.Cut(pattern, " ")
+	if !found {
+		method, path = "", pattern
+	}
+	path = strings.TrimLeft(path, " ")
+
+	segments, err := parsePattern(path)
+	if err != nil {
+		panic(fmt.Sprintf("router: pattern %q: %v", pattern, err))
+	}
+
+	for _, r := range rt.routes {
+		if r.method == method && sameShape(r.segments, segments) {
+			panic(fmt.Sprintf("router: pattern %q conflicts with %q", pattern, r.pattern))
+		}
+	}
+
+	rt.routes = append(rt.routes, &route{
+		method:   method,
+		pattern:  pattern,
+		segments: segments,
+		handler:  handler,
+		limits:   limits,
+	})
+}
+
+// ServeRequest is a Handler that dispatches to the best matching route.
+// HEAD requests fall back to the GET route when no HEAD route matches;
+// the server then sends the GET response's headers without its body.
+//
+// Matching uses the decoded, dot-segment-free Target.Path, so an encoded
+// slash ("%2F") stays part of one segment or parameter; authority- and
+// asterisk-form targets name no path and get 404.
+func (rt *Router) ServeRequest(w *response.Writer, req *request.Request) {
+	best, params, allowed := rt.lookup(req)
+
+	switch {
+	case best != nil:
+		req.Params = params
+		best.handler(w, req)
+	case len(allowed) > 0:
+		if slices.Contains(allowed, "GET") {
+			allowed = append(allowed, "HEAD")
+		}
+		slices.Sort(allowed)
+		w.Headers.Set("allow", strings.Join(slices.Compact(allowed), ", "))
+		w.Status = response.METHOD_NOT_ALLOWED
+		w.SetBody([]byte("405 Method Not Allowed\n"))
+	default:
+		notFound(w)
+	}
+}
+
+// Limits returns the limits registered for the route req goes to (see
+// HandleLimits), or zero Limits. It fits Config.RouteLimits.
+func (rt *Router) Limits(req *request.Request) request.Limits {
+	if best, _, _ := rt.lookup(req); best != nil {
+		return best.limits
+	}
+	return request.Limits{}
+}
+
+// lookup finds the route for req (see find), letting HEAD fall back to
+// GET. Authority- and asterisk-form targets have no path and match nothing.
+func (rt *Router) lookup(req *request.Request) (best *route, params map[string]string, allowed []string) {
+	target := req.RequestLine.Target
+	if target == nil || target.Path == "" {
+		return nil, nil, nil
+	}
+	parts := splitPath(target.Path)
+
+	method := req.RequestLine.Method
+	best, params, allowed = rt.find(method, parts)
+	if best == nil && method == "HEAD" {
+		best, params, _ = rt.find("GET", parts)
+	}
+	return best, params, allowed
+}
+
+func notFound(w *response.Writer) {
+	w.Status = response.NOT_FOUND
+	w.SetBody([]byte("404 Not Found\n"))
+}
+
+// find returns the most specific route for method and path with its
+// parameters, plus the methods of routes matching the path but not method.
+func (rt *Router) find(method string, parts []string) (best *route, bestParams map[string]string, allowed []string) {
+	for _, r := range rt.routes {
+		params, ok := r.match(parts)
+		if !ok {
+			continue
+		}
+
+		if r.method != "" && r.method != method {
+			allowed = append(allowed, r.method)
+			continue
+		}
+		if best == nil || moreSpecific(r, best) {
+			best, bestParams = r, params
+		}
+	}
+	return best, bestParams, allowed
+}
+
+// match reports whether the path segments fit the route and returns the
+// captured parameters.
+func (r *route) match(parts []string) (map[string]string, bool) {
+	var params map[string]string
+	capture := func(name, value string) {
+		if params == nil {
+			params = make(map[string]string)
+		}
+		params[name] = value
+	}
+
+	for i, seg := range r.segments {
+		if seg.kind == segWildcard {
+			capture(seg.value, strings.Join(parts[i:], "/"))
+			return params, true
+		}
+		if i >= len(parts) {
+			return nil, false
+		}
+		switch seg.kind {
+		case segLiteral:
+			if parts[i] != seg.value {
+				return nil, false
+			}
+		case segParam:
+			if parts[i] == "" {
+				return nil, false
+			}
+			capture(seg.value, parts[i])
+		}
+	}
+
+	if len(parts) != len(r.segments) {
+		return nil, false
+	}
+	return params, true
+}
+
+// moreSpecific reports whether a should win over b when both match.
+func moreSpecific(a, b *route) bool {
+	for i := 0; i < len(a.segments) && i < len(b.segments); i++ {
+		if a.segments[i].kind != b.segments[i].kind {
+			return a.segments[i].kind < b.segments[i].kind
+		}
+	}
+	// Same shape: a method-specific route beats a catch-all one.
+	return a.method != "" && b.method == ""
+}
+
+// sameShape reports whether two patterns match exactly the same paths
+// (parameter names aside).
+func sameShape(a, b []segment) bool {
+	return slices.EqualFunc(a, b, func(x, y segment) bool {
+		return x.kind == y.kind && (x.kind != segLiteral || x.value == y.value)
+	})
+}
+
+// splitPath turns "/a/b" into ["a", "b"] and "/" into [""].
+func splitPath(path string) []string {
+	return strings.Split(strings.TrimPrefix(path, "/"), "/")
+}
+
+func parsePattern(path string) ([]segment, error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("path must start with '/'")
+	}
+
+	parts := splitPath(path)
+	segments := make([]segment, 0, len(parts))
+	seen := make(map[string]bool)
+
+	for i, part := range parts {
+		if !strings.HasPrefix(part, "{") {
+			if strings.ContainsAny(part, "{}") {
+				return nil, fmt.Errorf("segment %q mixes text and a parameter", part)
+			}
+			segments = append(segments, segment{kind: segLiteral, value: part})
+			continue
+		}
+
+		if !strings.HasSuffix(part, "}") {
+			return nil, fmt.Errorf("unterminated parameter %q", part)
+		}
+		name := part[1 : len(part)-1]
+		kind := segParam
+		if rest, ok := strings.CutSuffix(name, "..."); ok {
+			if i != len(parts)-1 {
+				return nil, fmt.Errorf("%q must be the last segment", part)
+			}
+			name, kind = rest, segWildcard
+		}
+		if name == "" || strings.ContainsAny(name, "{}/") {
+			return nil, fmt.Errorf("bad parameter name in %q", part)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate parameter %q", name)
+		}
+		seen[name] = true
+
+		segments = append(segments, segment{kind: kind, value: name})
+	}
+	return segments, nil
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"bytes"
+	"httpfromtcp/internal/headers"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dispatch runs rt against a bare request and returns the writer the
+// handler (or the router itself) filled in.
+func dispatch(t *testing.T, rt *Router, method, target string) (*response.Writer, *request.Request) {
+	t.Helper()
+	req, err := request.RequestFromReader(strings.NewReader(method + " " + target + " HTTP/1.1\r\n\r\n"))
+	require.NoError(t, err)
+
+	w := response.NewWriter(&bytes.Buffer{})
+	w.Headers = headers.NewHeaders()
+	rt.ServeRequest(w, req)
+	return w, req
+}
+
+func named(name string) Handler {
+	return func(w *response.Writer, req *request.Request) {
+		w.Status = response.OK
+		w.SetBody([]byte(name))
+	}
+}
+
+func TestRouter(t *testing.T) {
+	rt := NewRouter()
+	rt.Handle("GET /users/{id}", named("user"))
+	rt.Handle("GET /users/me", named("me"))
+	rt.Handle("DELETE /users/{id}", named("delete"))
+	rt.Handle("GET /files/{path...}", named("files"))
+	rt.Handle("/any", named("any"))
+
+	// Test: Path parameter
+	w, req := dispatch(t, rt, "GET", "/users/42?x=1")
+	assert.Equal(t, "user", string(w.Body))
+	assert.Equal(t, "42", req.Param("id"))
+
+	// Test: An encoded slash stays inside the parameter
+	w, req = dispatch(t, rt, "GET", "/users/a%2Fb")
+	assert.Equal(t, "user", string(w.Body))
+	assert.Equal(t, "a%2Fb", req.Param("id"))
+
+	// Test: Literal beats parameter
+	w, _ = dispatch(t, rt, "GET", "/users/me")
+	assert.Equal(t, "me", string(w.Body))
+
+	// Test: Method selects the route
+	w, req = dispatch(t, rt, "DELETE", "/users/7")
+	assert.Equal(t, "delete", string(w.Body))
+	assert.Equal(t, "7", req.Param("id"))
+
+	// Test: Wildcard captures the remainder
+	w, req = dispatch(t, rt, "GET", "/files/a/b/c.txt")
+	assert.Equal(t, "files", string(w.Body))
+	assert.Equal(t, "a/b/c.txt", req.Param("path"))
+
+	// Test: Pattern without a method matches any method
+	w, _ = dispatch(t, rt, "PATCH", "/any")
+	assert.Equal(t, "any", string(w.Body))
+
+	// Test: Known path, wrong method => 405 with Allow
+	w, _ = dispatch(t, rt, "POST", "/users/42")
+	assert.Equal(t, response.METHOD_NOT_ALLOWED, w.Status)
+	assert.Equal(t, "DELETE, GET, HEAD", w.Headers.Get("Allow"))
+
+	// Test: HEAD falls back to the GET route
+	w, req = dispatch(t, rt, "HEAD", "/users/42")
+	assert.Equal(t, "user", string(w.Body))
+	assert.Equal(t, "42", req.Param("id"))
+
+	// Test: Explicit HEAD route wins over the GET fallback
+	rt.Handle("HEAD /users/{id}", named("head"))
+	w, _ = dispatch(t, rt, "HEAD", "/users/42")
+	assert.Equal(t, "head", string(w.Body))
+
+	// Test: HEAD without a GET route is still 405
+	rt.Handle("DELETE /gone", named("gone"))
+	w, _ = dispatch(t, rt, "HEAD", "/gone")
+	assert.Equal(t, response.METHOD_NOT_ALLOWED, w.Status)
+	assert.Equal(t, "DELETE", w.Headers.Get("Allow"))
+
+	// Test: Unknown path => 404
+	w, _ = dispatch(t, rt, "GET", "/nope")
+	assert.Equal(t, response.NOT_FOUND, w.Status)
+	w, _ = dispatch(t, rt, "GET", "/users/")
+	assert.Equal(t, response.NOT_FOUND, w.Status)
+	w, _ = dispatch(t, rt, "GET", "/users/1/extra")
+	assert.Equal(t, response.NOT_FOUND, w.Status)
+
+	// Test: Matching uses the decoded, normalized path
+	w, req = dispatch(t, rt, "GET", "/files/../users/./J%C3%BCrgen")
+	assert.Equal(t, "user", string(w.Body))
+	assert.Equal(t, "Jürgen", req.Param("id"))
+	w, _ = dispatch(t, rt, "GET", "http://example.com/users/me")
+	assert.Equal(t, "me", string(w.Body))
+
+	// Test: No path to route => 404
+	w, _ = dispatch(t, rt, "OPTIONS", "*")
+	assert.Equal(t, response.NOT_FOUND, w.Status)
+}
+
+func TestRouterBadPatterns(t *testing.T) {
+	rt := NewRouter()
+	rt.Handle("GET /a/{id}", named("a"))
+
+	assert.Panics(t, func() { rt.Handle("GET /a/{other}", named("dup")) })
+	assert.Panics(t, func() { rt.Handle("GET no-slash", named("x")) })
+	assert.Panics(t, func() { rt.Handle("GET /{rest...}/tail", named("x")) })
+	assert.Panics(t, func() { rt.Handle("GET /x{id}", named("x")) })
+	assert.Panics(t, func() { rt.Handle("GET /{id}/{id}", named("x")) })
+	assert.NotPanics(t, func() { rt.Handle("POST /a/{id}", named("post")) })
+}
+
+func TestRouterLimits(t *testing.T) {
+	rt := NewRouter()
+	rt.HandleLimits("POST /upload", request.Limits{MaxBodyBytes: 1 << 30}, named("upload"))
+	rt.Handle("GET /files/{path...}", named("files"))
+
+	limitsFor := func(method, target string) request.Limits {
+		req, err := request.RequestFromReader(strings.NewReader(method + " " + target + " HTTP/1.1\r\n\r\n"))
+		require.NoError(t, err)
+		return rt.Limits(req)
+	}
+	assert.Equal(t, request.Limits{MaxBodyBytes: 1 << 30}, limitsFor("POST", "/upload"))
+	assert.Equal(t, request.Limits{}, limitsFor("GET", "/files/a"))
+	assert.Equal(t, request.Limits{}, limitsFor("GET", "/upload"))
+	assert.Equal(t, request.Limits{}, limitsFor("GET", "/nope"))
+
+	// Test: The registered handler still serves the route
+	w, _ := dispatch(t, rt, "POST", "/upload")
+	assert.Equal(t, "upload", string(w.Body))
+}
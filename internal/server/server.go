@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"httpfromtcp/internal/headers"
@@ -9,6 +10,10 @@ import (
 	"io"
 	"log"
 	"net"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -18,16 +23,102 @@ type Server struct {
 	listener net.Listener
 	closed   atomic.Bool
 	handler  Handler
+	config   Config
+
+	mu    sync.Mutex
+	conns map[net.Conn]bool // open connections -> idle between requests
+}
+
+// Config holds optional Server settings. The zero value keeps the
+// defaults used by Serve.
+type Config struct {
+	// StreamRequestBody hands requests to the Handler as soon as the
+	// headers are parsed; the body is read from the connection through
+	// req.BodyReader instead of being buffered into req.Body first.
+	StreamRequestBody bool
+
+	// ReadHeaderTimeout bounds reading the start-line and headers of a
+	// request, from its first byte. Zero falls back to ReadTimeout.
+	ReadHeaderTimeout time.Duration
+	// ReadTimeout bounds reading a whole request, body included.
+	// Zero means no timeout.
+	ReadTimeout time.Duration
+	// WriteTimeout bounds handling a request and writing its response,
+	// from the end of the header section. Zero means no timeout.
+	WriteTimeout time.Duration
+	// IdleTimeout bounds the wait for the next request on a keep-alive
+	// connection. Zero falls back to ReadTimeout.
+	IdleTimeout time.Duration
+
+	// ServerName, if set, is sent as the Server header.
+	ServerName string
+	// DefaultContentType is sent when a handler sets no Content-Type.
+	// Empty means "text/plain".
+	DefaultContentType string
+	// OmitContentType sends no Content-Type unless the handler sets one.
+	OmitContentType bool
+
+	// Limits caps request sizes; zero fields use request.DefaultLimits.
+	Limits request.Limits
+	// RouteLimits, if set, picks per-request limits once the headers are
+	// parsed, e.g. Router.Limits. Zero fields keep Limits.
+	RouteLimits func(*request.Request) request.Limits
+}
+
+// defaultHeaders returns the fields every response gets unless the handler
+// sets them: Date (RFC 9110 6.6.1), plus Server and Content-Type per config.
+func (c Config) defaultHeaders() headers.Headers {
+	h := headers.NewHeaders()
+	h.Set("date", httpDate.now())
+	if c.ServerName != "" {
+		h.Set("server", c.ServerName)
+	}
+	if !c.OmitContentType {
+		ct := c.DefaultContentType
+		if ct == "" {
+			ct = "text/plain"
+		}
+		h.Set("content-type", ct)
+	}
+	return h
+}
+
+func (c Config) readHeaderTimeout() time.Duration {
+	if c.ReadHeaderTimeout > 0 {
+		return c.ReadHeaderTimeout
+	}
+	return c.ReadTimeout
 }
 
-type HandlerError struct {
-	StatusCode response.StatusCode
-	Message    string
+func (c Config) idleTimeout() time.Duration {
+	if c.IdleTimeout > 0 {
+		return c.IdleTimeout
+	}
+	return c.ReadTimeout
+}
+
+// deadline turns a timeout into a net.Conn deadline; zero means none.
+func deadline(start time.Time, d time.Duration) time.Time {
+	if d <= 0 {
+		return time.Time{}
+	}
+	return start.Add(d)
 }
 
 type Handler func(w *response.Writer, req *request.Request)
 
 func Serve(port int, handler Handler) (*Server, error) {
+	return ServeConfig(port, handler, Config{})
+}
+
+// ServeConfig is like Serve but with explicit settings.
+func ServeConfig(port int, handler Handler, config Config) (*Server, error) {
+	// Default headers go out with every response; refuse values that
+	// would split it (e.g. CR/LF in ServerName) before serving anything.
+	if err := response.ValidateHeaders(config.defaultHeaders()); err != nil {
+		return nil, fmt.Errorf("server config: %w", err)
+	}
+
 	l, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
 	if err != nil {
 		return nil, err
@@ -36,11 +127,15 @@ func Serve(port int, handler Handler) (*Server, error) {
 		Port:     port,
 		listener: l,
 		handler:  handler,
+		config:   config,
+		conns:    make(map[net.Conn]bool),
 	}
 	go s.listen()
 	return s, nil
 }
 
+// Close stops accepting new connections. Open connections are left alone;
+// use Shutdown to wait for them.
 func (s *Server) Close() error {
 	// Make Close idempotent.
 	if s.closed.Swap(true) {
@@ -49,6 +144,70 @@ func (s *Server) Close() error {
 	return s.listener.Close()
 }
 
+// How often Shutdown checks whether in-flight connections are done.
+const shutdownPollInterval = 10 * time.Millisecond
+
+// Shutdown stops accepting new connections, closes idle keep-alive
+// connections, and waits for in-flight requests to finish (their
+// connections close after the response). If ctx expires first, the
+// remaining connections are force-closed and ctx.Err() is returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	err := s.Close()
+
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for {
+		if s.closeIdleConns() {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			s.closeAllConns()
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// closeIdleConns closes connections waiting for their next request and
+// reports whether no connections are left at all.
+func (s *Server) closeIdleConns() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn, idle := range s.conns {
+		if idle {
+			_ = conn.Close()
+			delete(s.conns, conn)
+		}
+	}
+	return len(s.conns) == 0
+}
+
+func (s *Server) closeAllConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		_ = conn.Close()
+		delete(s.conns, conn)
+	}
+}
+
+// trackConn records conn as open (active or idle) or, with open=false,
+// forgets it. Connections opened after Close are refused.
+func (s *Server) trackConn(conn net.Conn, open, idle bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !open {
+		delete(s.conns, conn)
+		return false
+	}
+	if _, ok := s.conns[conn]; !ok && s.closed.Load() {
+		return false
+	}
+	s.conns[conn] = idle
+	return true
+}
+
 func (s *Server) listen() {
 	for {
 		conn, err := s.listener.Accept()
@@ -63,77 +222,239 @@ func (s *Server) listen() {
 	}
 }
 
+var errHandlerPanic = errors.New("handler panicked")
+
+// runHandler calls the handler, recovering a panic so one bad request
+// cannot take the whole process down. It reports whether it panicked.
+func (s *Server) runHandler(w *response.Writer, req *request.Request) (panicked bool) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			panicked = true
+			log.Printf("panic serving %s %s %s: %v\n%s",
+				req.RemoteAddr, req.RequestLine.Method, req.RequestLine.RequestTarget, rec, debug.Stack(),
+			)
+		}
+	}()
+
+	s.handler(w, req)
+	return false
+}
+
+// How long we try to deliver a bare error response to a client whose
+// request we gave up on.
+const errorWriteTimeout = 5 * time.Second
+
+// writeErrorStatus answers a request that could not be read with an empty
+// response and asks the client to close.
+func (s *Server) writeErrorStatus(conn net.Conn, status response.StatusCode) {
+	w := response.NewWriter(conn)
+	w.Defaults = s.config.defaultHeaders()
+	w.Defaults.Del("content-type") // no content to describe
+	if err := w.WriteStatusLine(status); err != nil {
+		return
+	}
+	h := headers.NewHeaders()
+	h.Set("connection", "close")
+	h.Set("content-length", "0")
+	if err := w.WriteHeaders(h); err != nil {
+		return
+	}
+	_ = w.Finish()
+}
+
 // helper: format duration compactly
 func fmtDur(d time.Duration) string {
 	return fmt.Sprintf("%.1fms", float64(d.Microseconds())/1000.0)
 }
 
+// wantsKeepAlive reports whether the client asked to reuse the connection:
+// HTTP/1.1 persists unless it sends Connection: close, HTTP/1.0 only with
+// Connection: keep-alive. A 1.0 message with Transfer-Encoding cannot be
+// trusted to be framed right, so that connection closes either way
+// (RFC 9112 6.1).
+func wantsKeepAlive(req *request.Request) bool {
+	if req.RequestLine.HTTPVersion == "1.0" {
+		return req.Headers.HasToken("connection", "keep-alive") &&
+			req.Headers.Get("transfer-encoding") == ""
+	}
+	return !req.Headers.HasToken("connection", "close")
+}
+
+// Unread request body bytes we are willing to discard after the handler
+// returns to keep the connection reusable; beyond this we just close.
+const maxDrainBytes = 256 * 1024 // 256 KiB
+
+// handle serves requests on conn until either side wants to close it.
 func (s *Server) handle(conn net.Conn) {
 	defer conn.Close()
-	start := time.Now()
+	defer s.trackConn(conn, false, false)
 
 	remoteHost, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
 
-	req, err := request.RequestFromReader(conn)
+	// One reader per connection so bytes of pipelined requests read
+	// together with the current one carry over to the next.
+	reader := request.NewReader(conn)
+	reader.Limits = s.config.Limits
+	reader.LimitsFor = s.config.RouteLimits
+
+	for first := true; ; first = false {
+		// Wait for the next request as an idle connection (Shutdown may
+		// close it); the header timeout starts once it begins to arrive.
+		wait := s.config.idleTimeout()
+		if first {
+			wait = s.config.readHeaderTimeout()
+		}
+		if !s.trackConn(conn, true, true) {
+			return // shutting down
+		}
+		_ = conn.SetReadDeadline(deadline(time.Now(), wait))
+		if err := reader.WaitForRequest(); err != nil {
+			return // closed or idle too long; nothing to answer
+		}
+		if !s.trackConn(conn, true, false) {
+			return // closed by Shutdown while idle
+		}
+
+		if !s.serveRequest(conn, reader, remoteHost) {
+			return
+		}
+	}
+}
+
+// serveRequest reads one request from reader and writes its response to conn.
+// It reports whether the connection can be reused for another request.
+func (s *Server) serveRequest(conn net.Conn, reader *request.Reader, remoteHost string) (keepAlive bool) {
+	start := time.Now()
+
+	_ = conn.SetReadDeadline(deadline(start, s.config.readHeaderTimeout()))
+	reader.OnHeaders = func(*request.Request) {
+		// Headers are in: the rest of the read falls under ReadTimeout,
+		// and the handler plus response under WriteTimeout.
+		_ = conn.SetReadDeadline(deadline(start, s.config.ReadTimeout))
+		_ = conn.SetWriteDeadline(deadline(time.Now(), s.config.WriteTimeout))
+	}
+
+	readRequest := reader.ReadRequest
+	if s.config.StreamRequestBody {
+		readRequest = reader.StreamRequest
+	}
+
+	req, err := readRequest()
 	if err != nil {
-		// Log the bad request with a 400 status
+		if errors.Is(err, io.EOF) {
+			return false // client closed an idle connection
+		}
+
+		// Parse errors carry their status (413, 414, 431, 501, 505, 400).
+		status := response.StatusCode(request.ErrorStatus(err))
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			status = response.REQUEST_TIMEOUT
+		}
+
 		log.Printf("%s\t%s\t%s\t%d\t%s\terr=%q",
-			remoteHost, "-", "-", 400, fmtDur(time.Since(start)), err.Error(),
+			remoteHost, "-", "-", int(status), fmtDur(time.Since(start)), err.Error(),
 		)
 		// Return a proper HTTP error so clients don’t see a reset.
-		_, _ = io.WriteString(conn, "HTTP/1.1 400 Bad Request\r\nConnection: close\r\nContent-Length: 0\r\n\r\n")
+		_ = conn.SetWriteDeadline(deadline(time.Now(), errorWriteTimeout))
+		s.writeErrorStatus(conn, status)
 
-		return
+		return false
 	}
 
+	req.RemoteAddr = conn.RemoteAddr().String()
 	method := req.RequestLine.Method
 	target := req.RequestLine.RequestTarget
 
 	// Build your response
 	writer := response.NewWriter(conn)
 	writer.Headers = headers.NewHeaders()
+	writer.Defaults = s.config.defaultHeaders()
+	// HEAD gets the headers a GET would, Content-Length included, and no body.
+	writer.SuppressBody = method == "HEAD"
+	// HTTP/1.0 clients cannot decode chunked bodies.
+	http10 := req.RequestLine.HTTPVersion == "1.0"
+	writer.NoChunking = http10
+	// Known up front, so a streamed response announces it too.
+	switch {
+	case !wantsKeepAlive(req) || s.closed.Load():
+		writer.Headers.Set("connection", "close")
+	case http10:
+		writer.Headers.Set("connection", "keep-alive")
+	}
 
-	s.handler(writer, req)
+	// 200 unless the handler says otherwise, so middleware such as
+	// AccessLog sees the status that goes out.
+	writer.Status = response.OK
 
-	// 1) status line
-	if err := writer.WriteStatusLine(writer.Status); err != nil {
-		log.Printf("%s\t%s\t%s\t%d\t%s\terr=%q",
-			remoteHost, method, target, 500, fmtDur(time.Since(start)), err.Error(),
-		)
-		return
+	panicked := s.runHandler(writer, req)
+	if panicked && writer.StatusWritten() {
+		return false // response is half out; all we can do is hang up
+	}
+	if panicked {
+		// Drop whatever the handler set up and answer 500 instead.
+		writer.Headers = headers.NewHeaders()
+		writer.Status = response.INTERNAL_SERVER_ERROR
+		writer.SetBody(nil)
+		writeHandlerError(writer, req, errHandlerPanic)
+	}
+	if !writer.StatusWritten() {
+		// A field that would split the response is a handler bug; answer
+		// 500 instead of sending it.
+		if err := response.ValidateHeaders(writer.Headers); err != nil {
+			writer.Headers = headers.NewHeaders()
+			writer.SetBody(nil)
+			writeHandlerError(writer, req, err)
+		}
 	}
 
-	// 2) headers (with correct Content-Length)
-	h := response.GetDefaultHeaders(len(writer.Body))
-	if err := writer.WriteHeaders(h); err != nil {
-		log.Printf("%s\t%s\t%s\t%d\t%s\terr=%q",
-			remoteHost, method, target, 500, fmtDur(time.Since(start)), err.Error(),
-		)
-		return
+	// Reuse the connection if the client wants to and nothing on our side
+	// says close (a close-delimited body ends by closing it).
+	keepAlive = wantsKeepAlive(req) &&
+		!writer.Headers.HasToken("connection", "close") &&
+		!writer.CloseDelimited() &&
+		!s.closed.Load() && !panicked
+
+	// Skip whatever body the handler left unread so the next request
+	// starts at the right place; give up on large or broken leftovers.
+	if !reader.DiscardBody(maxDrainBytes) {
+		keepAlive = false
 	}
+	_ = req.BodyReader.Close()
 
-	// 3) body
-	if writer.Headers.Get("Transfer-Encoding") == "chunked" {
-		_, err := writer.WriteChunkedBody(writer.Body)
-		if err != nil {
-			return
+	if !writer.StatusWritten() {
+		// Buffered: the handler only filled in Status, Headers and Body.
+		// 1) status line
+		if err := writer.WriteStatusLine(writer.Status); err != nil {
+			log.Printf("%s\t%s\t%s\t%d\t%s\terr=%q",
+				remoteHost, method, target, 500, fmtDur(time.Since(start)), err.Error(),
+			)
+			return false
 		}
-		_, err = writer.WriteChunkedBodyDone()
-		if err != nil {
-			return
+
+		// 2) headers (with correct Content-Length)
+		h := headers.NewHeaders()
+		h.Set("content-length", strconv.Itoa(len(writer.Body)))
+		if !keepAlive {
+			writer.Headers.Set("connection", "close")
+		} else if http10 {
+			writer.Headers.Set("connection", "keep-alive")
 		}
-	} else {
-		_, err = writer.WriteBody(writer.Body)
-		if err != nil {
+		if err := writer.WriteHeaders(h); err != nil {
 			log.Printf("%s\t%s\t%s\t%d\t%s\terr=%q",
 				remoteHost, method, target, 500, fmtDur(time.Since(start)), err.Error(),
 			)
-			return
+			return false
 		}
 	}
 
-	// Access log (success)
-	log.Printf("%s\t%s\t%s\t%d\t%s",
-		remoteHost, method, target, int(writer.Status), fmtDur(time.Since(start)),
-	)
+	// 3) body: all of it when buffered, whatever is left when streamed,
+	// then the last chunk if chunked.
+	if err := writer.Finish(); err != nil {
+		log.Printf("%s\t%s\t%s\t%d\t%s\terr=%q",
+			remoteHost, method, target, 500, fmtDur(time.Since(start)), err.Error(),
+		)
+		return false
+	}
+
+	return keepAlive
 }
@@ -0,0 +1,404 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"httpfromtcp/internal/headers"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startServer serves handler on a free loopback port and returns the address
+// to dial. The server is shut down when the test ends.
+func startServer(t *testing.T, handler Handler, config Config) (*Server, string) {
+	t.Helper()
+	s, err := ServeConfig(0, handler, config)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = s.Shutdown(ctx)
+	})
+	_, port, err := net.SplitHostPort(s.listener.Addr().String())
+	require.NoError(t, err)
+	return s, net.JoinHostPort("127.0.0.1", port)
+}
+
+// dial opens a client connection to addr with a safety deadline so a
+// broken test fails instead of hanging.
+func dial(t *testing.T, addr string) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+	return conn, bufio.NewReader(conn)
+}
+
+// readResponse reads one response to a request with the given method and
+// returns it with its whole body.
+func readResponse(t *testing.T, br *bufio.Reader, method string) (*http.Response, string) {
+	t.Helper()
+	resp, err := http.ReadResponse(br, &http.Request{Method: method})
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	return resp, string(body)
+}
+
+// assertClosed checks that the server closed conn without sending more.
+func assertClosed(t *testing.T, br *bufio.Reader) {
+	t.Helper()
+	rest, err := io.ReadAll(br)
+	require.NoError(t, err)
+	assert.Empty(t, string(rest))
+}
+
+func echo(w *response.Writer, req *request.Request) {
+	w.Status = response.OK
+	w.SetBody(req.Body)
+}
+
+func TestReadHeaderTimeout(t *testing.T) {
+	_, addr := startServer(t, echo, Config{ReadHeaderTimeout: 50 * time.Millisecond})
+	conn, br := dial(t, addr)
+
+	// Test: Headers that never finish get a 408 and the connection closes
+	_, err := io.WriteString(conn, "GET / HTTP/1.1\r\nHost: x\r\n")
+	require.NoError(t, err)
+	resp, body := readResponse(t, br, "GET")
+	assert.Equal(t, http.StatusRequestTimeout, resp.StatusCode)
+	assert.True(t, resp.Close)
+	assert.Empty(t, body)
+	assertClosed(t, br)
+}
+
+func TestIdleTimeout(t *testing.T) {
+	_, addr := startServer(t, echo, Config{IdleTimeout: 50 * time.Millisecond})
+	conn, br := dial(t, addr)
+
+	_, err := io.WriteString(conn, "GET / HTTP/1.1\r\nHost: x\r\n\r\n")
+	require.NoError(t, err)
+	resp, _ := readResponse(t, br, "GET")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.False(t, resp.Close)
+
+	// Test: An idle keep-alive connection is closed without a response
+	start := time.Now()
+	assertClosed(t, br)
+	assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+}
+
+func TestTimeoutsAfterHeaders(t *testing.T) {
+	_, addr := startServer(t, echo, Config{
+		ReadHeaderTimeout: 50 * time.Millisecond,
+		ReadTimeout:       2 * time.Second,
+	})
+	conn, br := dial(t, addr)
+
+	// Test: Once the headers are in, a body slower than ReadHeaderTimeout
+	// still arrives within ReadTimeout
+	_, err := io.WriteString(conn, "POST / HTTP/1.1\r\nHost: x\r\nContent-Length: 5\r\n\r\n")
+	require.NoError(t, err)
+	time.Sleep(150 * time.Millisecond)
+	_, err = io.WriteString(conn, "hello")
+	require.NoError(t, err)
+	resp, body := readResponse(t, br, "POST")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "hello", body)
+
+	// Test: ReadTimeout still bounds the body
+	_, addr = startServer(t, echo, Config{
+		ReadHeaderTimeout: time.Second,
+		ReadTimeout:       100 * time.Millisecond,
+	})
+	conn, br = dial(t, addr)
+	_, err = io.WriteString(conn, "POST / HTTP/1.1\r\nHost: x\r\nContent-Length: 5\r\n\r\nhe")
+	require.NoError(t, err)
+	resp, _ = readResponse(t, br, "POST")
+	assert.Equal(t, http.StatusRequestTimeout, resp.StatusCode)
+	assertClosed(t, br)
+
+	// Test: WriteTimeout starts at the end of the headers and cuts off a
+	// handler that is too slow
+	slow := func(w *response.Writer, req *request.Request) {
+		time.Sleep(150 * time.Millisecond)
+		_ = w.WriteStatusLine(response.OK)
+	}
+	_, addr = startServer(t, slow, Config{WriteTimeout: 50 * time.Millisecond})
+	conn, br = dial(t, addr)
+	_, err = io.WriteString(conn, "GET / HTTP/1.1\r\nHost: x\r\n\r\n")
+	require.NoError(t, err)
+	assertClosed(t, br)
+}
+
+func TestShutdown(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	block := func(w *response.Writer, req *request.Request) {
+		if req.RequestLine.RequestTarget == "/block" {
+			close(started)
+			<-release
+		}
+		w.Status = response.OK
+	}
+	s, addr := startServer(t, block, Config{})
+
+	idle, idleBR := dial(t, addr)
+	_, err := io.WriteString(idle, "GET / HTTP/1.1\r\nHost: x\r\n\r\n")
+	require.NoError(t, err)
+	resp, _ := readResponse(t, idleBR, "GET")
+	assert.False(t, resp.Close)
+
+	busy, busyBR := dial(t, addr)
+	_, err = io.WriteString(busy, "GET /block HTTP/1.1\r\nHost: x\r\n\r\n")
+	require.NoError(t, err)
+	<-started
+
+	done := make(chan error, 1)
+	go func() { done <- s.Shutdown(context.Background()) }()
+
+	// Test: Idle keep-alive connections are closed right away
+	assertClosed(t, idleBR)
+
+	// Test: Shutdown waits for the in-flight request, which then gets
+	// Connection: close
+	select {
+	case err := <-done:
+		t.Fatalf("Shutdown returned before the request finished: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	resp, _ = readResponse(t, busyBR, "GET")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.True(t, resp.Close)
+	assertClosed(t, busyBR)
+	require.NoError(t, <-done)
+
+	// Test: No new connections are served
+	_, err = net.Dial("tcp", addr)
+	assert.Error(t, err)
+}
+
+func TestShutdownContextExpires(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	block := func(w *response.Writer, req *request.Request) {
+		close(started)
+		<-release
+	}
+	s, addr := startServer(t, block, Config{})
+
+	conn, br := dial(t, addr)
+	_, err := io.WriteString(conn, "GET / HTTP/1.1\r\nHost: x\r\n\r\n")
+	require.NoError(t, err)
+	<-started
+
+	// Test: Connections still busy when ctx expires are force-closed
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, s.Shutdown(ctx), context.DeadlineExceeded)
+	assertClosed(t, br)
+}
+
+func TestShutdownRacesAccept(t *testing.T) {
+	s, addr := startServer(t, echo, Config{})
+
+	// Test: Connections accepted while Shutdown runs are either served or
+	// refused, and none are left tracked afterwards
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn, err := net.Dial("tcp", addr)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+			_, _ = io.WriteString(conn, "GET / HTTP/1.1\r\nHost: x\r\n\r\n")
+			_, _ = io.Copy(io.Discard, conn)
+		}()
+	}
+	require.NoError(t, s.Shutdown(context.Background()))
+	wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	assert.Empty(t, s.conns)
+}
+
+func TestHandlerPanic(t *testing.T) {
+	h := func(w *response.Writer, req *request.Request) {
+		if req.RequestLine.RequestTarget == "/streamed" {
+			h := headers.NewHeaders()
+			h.Set("transfer-encoding", "chunked")
+			_ = w.WriteStatusLine(response.OK)
+			_ = w.WriteHeaders(h)
+			_, _ = w.WriteChunkedBody([]byte("partial"))
+			_ = w.Flush()
+		}
+		w.Headers.Set("x-half-done", "yes")
+		panic("boom")
+	}
+	_, addr := startServer(t, h, Config{})
+
+	// Test: A panic before the status line becomes a 500 and closes the
+	// connection
+	conn, br := dial(t, addr)
+	_, err := io.WriteString(conn, "GET / HTTP/1.1\r\nHost: x\r\n\r\n")
+	require.NoError(t, err)
+	resp, body := readResponse(t, br, "GET")
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.True(t, resp.Close)
+	assert.Empty(t, resp.Header.Get("X-Half-Done"))
+	assert.NotContains(t, body, "boom")
+	assertClosed(t, br)
+
+	// Test: A panic after the status line went out just hangs up
+	conn, br = dial(t, addr)
+	_, err = io.WriteString(conn, "GET /streamed HTTP/1.1\r\nHost: x\r\n\r\n")
+	require.NoError(t, err)
+	resp, err = http.ReadResponse(br, &http.Request{Method: "GET"})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	_, err = io.ReadAll(resp.Body)
+	assert.Error(t, err) // chunked body cut off without its last chunk
+}
+
+func TestInvalidHeaderWhileStreaming(t *testing.T) {
+	h := func(w *response.Writer, req *request.Request) {
+		w.Headers.Set("x-bad", "a\r\nb")
+		_, _ = w.Write([]byte("streamed"))
+	}
+	_, addr := startServer(t, h, Config{})
+	conn, br := dial(t, addr)
+
+	// Test: A streaming handler's bad field still gets a 500, not a hang-up
+	_, err := io.WriteString(conn, "GET / HTTP/1.1\r\nHost: x\r\n\r\n")
+	require.NoError(t, err)
+	resp, body := readResponse(t, br, "GET")
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("X-Bad"))
+	assert.NotContains(t, body, "streamed")
+}
+
+func TestWantsKeepAlive(t *testing.T) {
+	parse := func(raw string) *request.Request {
+		req, err := request.RequestFromReader(strings.NewReader(raw))
+		require.NoError(t, err)
+		return req
+	}
+	assert.True(t, wantsKeepAlive(parse("GET / HTTP/1.1\r\nHost: x\r\n\r\n")))
+	assert.False(t, wantsKeepAlive(parse("GET / HTTP/1.1\r\nHost: x\r\nConnection: Close\r\n\r\n")))
+	assert.False(t, wantsKeepAlive(parse("GET / HTTP/1.1\r\nHost: x\r\nConnection: foo, close\r\n\r\n")))
+	assert.False(t, wantsKeepAlive(parse("GET / HTTP/1.0\r\n\r\n")))
+	assert.True(t, wantsKeepAlive(parse("GET / HTTP/1.0\r\nConnection: keep-alive\r\n\r\n")))
+}
+
+func TestKeepAlive(t *testing.T) {
+	_, addr := startServer(t, echo, Config{})
+	conn, br := dial(t, addr)
+
+	// Test: Two requests share one connection; the first stays open
+	_, err := io.WriteString(conn, "POST / HTTP/1.1\r\nHost: x\r\nContent-Length: 3\r\n\r\none")
+	require.NoError(t, err)
+	resp, body := readResponse(t, br, "POST")
+	assert.Equal(t, "one", body)
+	assert.False(t, resp.Close)
+	assert.Empty(t, resp.Header.Values("Connection"))
+
+	// Test: The second asks to close and gets Connection: close back
+	_, err = io.WriteString(conn, "POST / HTTP/1.1\r\nHost: x\r\nConnection: close\r\nContent-Length: 3\r\n\r\ntwo")
+	require.NoError(t, err)
+	resp, body = readResponse(t, br, "POST")
+	assert.Equal(t, "two", body)
+	assert.True(t, resp.Close)
+	assertClosed(t, br)
+
+	// Test: Pipelined requests read together are answered in order
+	conn, br = dial(t, addr)
+	_, err = io.WriteString(conn, "POST / HTTP/1.1\r\nHost: x\r\nContent-Length: 1\r\n\r\na"+
+		"POST / HTTP/1.1\r\nHost: x\r\nContent-Length: 1\r\nConnection: close\r\n\r\nb")
+	require.NoError(t, err)
+	_, body = readResponse(t, br, "POST")
+	assert.Equal(t, "a", body)
+	resp, body = readResponse(t, br, "POST")
+	assert.Equal(t, "b", body)
+	assert.True(t, resp.Close)
+	assertClosed(t, br)
+}
+
+func TestHTTP10(t *testing.T) {
+	h := func(w *response.Writer, req *request.Request) {
+		if req.RequestLine.RequestTarget != "/stream" {
+			echo(w, req)
+			return
+		}
+		hs := headers.NewHeaders()
+		hs.Set("transfer-encoding", "chunked")
+		_ = w.WriteStatusLine(response.OK)
+		_ = w.WriteHeaders(hs)
+		_, _ = w.WriteChunkedBody([]byte("hello "))
+		_, _ = w.WriteChunkedBody([]byte("world"))
+		_, _ = w.WriteChunkedBodyDone()
+	}
+	_, addr := startServer(t, h, Config{})
+	conn, br := dial(t, addr)
+
+	// Test: HTTP/1.0 keep-alive is echoed and the connection reused
+	_, err := io.WriteString(conn, "POST / HTTP/1.0\r\nConnection: keep-alive\r\nContent-Length: 3\r\n\r\none")
+	require.NoError(t, err)
+	resp, body := readResponse(t, br, "POST")
+	assert.Equal(t, "one", body)
+	assert.Equal(t, "keep-alive", resp.Header.Get("Connection"))
+	assert.Equal(t, int64(3), resp.ContentLength)
+	assert.False(t, resp.Close)
+
+	// Test: Without keep-alive an HTTP/1.0 connection closes
+	_, err = io.WriteString(conn, "POST / HTTP/1.0\r\nContent-Length: 3\r\n\r\ntwo")
+	require.NoError(t, err)
+	resp, body = readResponse(t, br, "POST")
+	assert.Equal(t, "two", body)
+	assert.True(t, resp.Close)
+	assertClosed(t, br)
+
+	// Test: A streamed body of unknown length is not chunked for HTTP/1.0
+	// but ends by closing the connection, even if keep-alive was asked for
+	conn, br = dial(t, addr)
+	_, err = io.WriteString(conn, "GET /stream HTTP/1.0\r\nConnection: keep-alive\r\n\r\n")
+	require.NoError(t, err)
+	resp, body = readResponse(t, br, "GET")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, resp.TransferEncoding)
+	assert.Equal(t, int64(-1), resp.ContentLength)
+	assert.True(t, resp.Close)
+	assert.Equal(t, "hello world", body)
+	assertClosed(t, br)
+}
+
+func TestDefaultStatus(t *testing.T) {
+	h := func(w *response.Writer, req *request.Request) {
+		w.SetBody([]byte("no status set"))
+	}
+	_, addr := startServer(t, h, Config{})
+	conn, br := dial(t, addr)
+
+	// Test: A buffered response without a Status is sent as 200 OK
+	_, err := io.WriteString(conn, "GET / HTTP/1.1\r\nHost: x\r\n\r\n")
+	require.NoError(t, err)
+	resp, body := readResponse(t, br, "GET")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "no status set", body)
+}